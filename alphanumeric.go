@@ -5,13 +5,11 @@
 package iso8583
 
 import (
-	"errors"
-	"fmt"
-	"strings"
+	"bytes"
 )
 
-// An Alphanumeric contains alphanumeric value in fix length. The only
-// supported encoder is ASCII. Length is required for marshaling and
+// An Alphanumeric contains alphanumeric value in fix length. Supported
+// encoders are ebcdic and ascii with its charsets. Length is required for marshaling and
 // unmarshaling.
 type Alphanumeric struct {
 	Value string
@@ -30,35 +28,59 @@ func (a *Alphanumeric) IsEmpty() bool {
 // Bytes encode Alphanumeric field to bytes
 func (a *Alphanumeric) Bytes(encoder, lenEncoder, length int) ([]byte, error) {
 	val := []byte(a.Value)
-	val, err := UTF8ToWindows1252(val)
+	padding := []byte(" ")
+	var err error
+	switch encoder {
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		val, err = ebcdicEncode(val, encoder)
+		if err != nil {
+			return nil, err
+		}
+		padding, err = ebcdicEncode(padding, encoder)
+	default:
+		val, err = charsetEncode(val, encoder)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	if length == -1 {
-		return nil, errors.New(ErrMissingLength)
+		return nil, ErrMissingLength
 	}
 	if len(val) > length {
-		return nil, fmt.Errorf(ErrValueTooLong, "Alphanumeric", length, len(val))
+		return nil, errValueTooLong("Alphanumeric", length, len(val))
 	}
 	if len(val) < length {
-		val = append([]byte(strings.Repeat(" ", length-len(val))), val...)
+		val = append(bytes.Repeat(padding, length-len(val)), val...)
 	}
 	return val, nil
 }
 
 // Load decode Alphanumeric field from bytes
 func (a *Alphanumeric) Load(raw []byte, encoder, lenEncoder, length int) (int, error) {
-	raw, err := UTF8ToWindows1252(raw)
-	if err != nil {
-		return 0, err
-	}
 	if length == -1 {
-		return 0, errors.New(ErrMissingLength)
+		return 0, ErrMissingLength
+	}
+	switch encoder {
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		if len(raw) < length {
+			return 0, ErrBadRaw
+		}
+		val, err := ebcdicDecode(raw[:length], encoder)
+		if err != nil {
+			return 0, err
+		}
+		a.Value = string(val)
+		return length, nil
 	}
+
 	if len(raw) < length {
-		return 0, errors.New(ErrBadRaw)
+		return 0, ErrBadRaw
+	}
+	val, err := charsetDecode(raw[:length], encoder)
+	if err != nil {
+		return 0, err
 	}
-	a.Value = string(raw[:length])
+	a.Value = string(val)
 	return length, nil
 }
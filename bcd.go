@@ -8,14 +8,14 @@ import (
 	"encoding/hex"
 )
 
-func lbcd(data []byte) []byte {
+func lbcd(data []byte) ([]byte, error) {
 	if len(data)%2 != 0 {
 		return bcd(append(data, "0"...))
 	}
 	return bcd(data)
 }
 
-func rbcd(data []byte) []byte {
+func rbcd(data []byte) ([]byte, error) {
 	if len(data)%2 != 0 {
 		return bcd(append([]byte("0"), data...))
 	}
@@ -23,13 +23,13 @@ func rbcd(data []byte) []byte {
 }
 
 // Encode numeric in ascii into bsd (be sure len(data) % 2 == 0)
-func bcd(data []byte) []byte {
+func bcd(data []byte) ([]byte, error) {
 	out := make([]byte, len(data)/2+1)
 	n, err := hex.Decode(out, data)
 	if err != nil {
-		panic(err.Error())
+		return nil, ErrInvalidBCD
 	}
-	return out[:n]
+	return out[:n], nil
 }
 
 func bcdl2Ascii(data []byte, length int) []byte {
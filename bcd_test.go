@@ -14,27 +14,29 @@ import (
 func TestBCDDecode(t *testing.T) {
 
 	b := []byte("954")
-	r := rbcd(b)
+	r, err := rbcd(b)
+	assert.NoError(t, err)
 	assert.Equal(t, "0954", fmt.Sprintf("%X", r))
 
-	r = lbcd(b)
+	r, err = lbcd(b)
+	assert.NoError(t, err)
 	assert.Equal(t, "9540", fmt.Sprintf("%X", r))
 
 	b = []byte("31")
-	r = lbcd(b)
+	r, _ = lbcd(b)
 	assert.Equal(t, "31", fmt.Sprintf("%X", r))
-	r = rbcd(b)
+	r, _ = rbcd(b)
 	assert.Equal(t, "31", fmt.Sprintf("%X", r))
 
 	b = []byte("123ab4")
-	assert.Equal(t, []byte("\x12\x3a\xb4"), bcd(b))
+	r, _ = bcd(b)
+	assert.Equal(t, []byte("\x12\x3a\xb4"), r)
 	b = []byte("00")
-	assert.Equal(t, []byte("\x00"), bcd(b))
+	r, _ = bcd(b)
+	assert.Equal(t, []byte("\x00"), r)
 
-	assert.Panics(t,
-		func() {
-			bcd([]byte("test"))
-		}, "Calling bcd() with invalid hex should panic")
+	_, err = bcd([]byte("test"))
+	assert.Equal(t, ErrInvalidBCD, err, "Calling bcd() with invalid hex should fail")
 
 }
 
@@ -4,11 +4,6 @@
 
 package iso8583
 
-import (
-	"errors"
-	"fmt"
-)
-
 // Binary contains binary value
 type Binary struct {
 	Value  []byte
@@ -32,10 +27,10 @@ func (b *Binary) Bytes(encoder, lenEncoder, l int) ([]byte, error) {
 		length = b.FixLen
 	}
 	if length == -1 {
-		return nil, errors.New(ErrMissingLength)
+		return nil, ErrMissingLength
 	}
 	if len(b.Value) > length {
-		return nil, fmt.Errorf(ErrValueTooLong, "Binary", length, len(b.Value))
+		return nil, errValueTooLong("Binary", length, len(b.Value))
 	}
 	if len(b.Value) < length {
 		return append(b.Value, make([]byte, length-len(b.Value))...), nil
@@ -46,10 +41,10 @@ func (b *Binary) Bytes(encoder, lenEncoder, l int) ([]byte, error) {
 // Load decode Binary field from bytes
 func (b *Binary) Load(raw []byte, encoder, lenEncoder, length int) (int, error) {
 	if length == -1 {
-		return 0, errors.New(ErrMissingLength)
+		return 0, ErrMissingLength
 	}
 	if len(raw) < length {
-		return 0, errors.New(ErrBadRaw)
+		return 0, ErrBadRaw
 	}
 	b.Value = raw[:length]
 	b.FixLen = length
@@ -0,0 +1,345 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package iso8583
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/moov-io/iso8583/pkg/lib"
+	"github.com/moov-io/iso8583/pkg/utils"
+)
+
+// encodings of pkg/lib for encoders of fields
+var (
+	libNumberEncodings = map[int]string{
+		ASCII: utils.EncodingChar,
+		BCD:   utils.EncodingBcd,
+		rBCD:  utils.EncodingRBcd,
+	}
+	libCharacterEncodings = map[int]string{
+		ASCII:  utils.EncodingAscii,
+		CP1252: utils.EncodingAscii,
+		EBCDIC: utils.EncodingEbcdic,
+	}
+	// length heads of pkg/lib are right-aligned BCD
+	libLengthEncodings = map[int]string{
+		ASCII: utils.EncodingChar,
+		BCD:   utils.EncodingRBcd,
+		rBCD:  utils.EncodingRBcd,
+	}
+)
+
+// TemplateSpecification derives specification of pkg/lib from template tpl.
+// Each field becomes an element described by the name of the struct field.
+// The encodings are taken from the first field of each kind, other fields
+// override them when they differ. Only fields up to 128 of types Numeric,
+// Llnumeric, Lllnumeric, Alphanumeric, Llvar, Lllvar and Binary are
+// supported.
+func TemplateSpecification(tpl interface{}) (*utils.Specification, error) {
+	tp := reflect.TypeOf(tpl)
+	if tp == nil {
+		return nil, &TemplateError{Err: errors.New("data must be a struct")}
+	}
+	tp = indirectType(tp)
+	compiled, err := templateOf(tp)
+	if err != nil {
+		return nil, err
+	}
+	if len(compiled.problems) > 0 {
+		return nil, compiled.problems
+	}
+
+	encoding := *utils.DefaultMessageEncoding
+	var numberSet, characterSet, lengthSet bool
+	elements := utils.Attributes{
+		1: {Describe: "b 64", Description: "Second Bitmap"},
+	}
+	for _, f := range compiled.fields {
+		sf := tp.Field(f.structIndex)
+		if f.Index > 128 {
+			return nil, templateError(sf, "fields above 128 aren't supported by specifications")
+		}
+		var describe string
+		var number, variable bool
+		switch field := f.wireField(sf).(type) {
+		case *Numeric:
+			describe, number = fmt.Sprintf("n %d", f.Length), true
+		case *Llnumeric:
+			describe, number, variable = fmt.Sprintf("n..%d", maxLength(f.Length, 99)), true, true
+		case *Lllnumeric:
+			describe, number, variable = fmt.Sprintf("n...%d", maxLength(f.Length, 999)), true, true
+		case *Alphanumeric:
+			describe = fmt.Sprintf("ans %d", f.Length)
+		case *Llvar:
+			describe, variable = fmt.Sprintf("ans..%d", maxLength(f.Length, 99)), true
+		case *Lllvar:
+			describe, variable = fmt.Sprintf("ans...%d", maxLength(f.Length, 999)), true
+		case *Binary:
+			elements[f.Index] = utils.Attribute{Describe: fmt.Sprintf("b %d", f.Length*8), Description: sf.Name}
+			continue
+		default:
+			return nil, templateError(sf, fmt.Sprintf("%s isn't supported by specifications", fieldTypeName(field)))
+		}
+		attr := utils.Attribute{Describe: describe, Description: sf.Name}
+
+		if enc, ok := libNumberEncodings[f.Encode]; ok && number {
+			attr.Encoding = libEncoding(&encoding.NumberEnc, &numberSet, enc)
+		}
+		if enc, ok := libCharacterEncodings[f.Encode]; ok && !number {
+			attr.Encoding = libEncoding(&encoding.CharacterEnc, &characterSet, enc)
+		}
+		if enc, ok := libLengthEncodings[f.LenEncode]; ok && variable {
+			attr.LengthEncoding = libEncoding(&encoding.LengthEnc, &lengthSet, enc)
+		}
+		elements[f.Index] = attr
+	}
+	return &utils.Specification{Elements: &elements, Encoding: &encoding}, nil
+}
+
+// Specification derives specification of pkg/lib from the template
+// registered for MTI. Native fields without pointer and omitempty option
+// are mandatory for the message type, the other fields are optional.
+func (p *Parser) Specification(mti string) (*utils.Specification, error) {
+	tp, ok := p.template(mti)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMti, mti)
+	}
+	spec, err := TemplateSpecification(reflect.New(tp).Interface())
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := templateOf(tp)
+	if err != nil {
+		return nil, err
+	}
+	mandatory := make([]byte, 16)
+	optional := make([]byte, 16)
+	for _, f := range compiled.fields {
+		mask := optional
+		if f.Type != "" && !f.OmitEmpty && tp.Field(f.structIndex).Type.Kind() != reflect.Ptr {
+			mask = mandatory
+		}
+		mask[(f.Index-1)/8] |= 0x80 >> uint((f.Index-1)%8)
+	}
+	spec.MessageTypes = &utils.MessageTypes{
+		mti: {
+			MandatoryHexMask: strings.ToUpper(fmt.Sprintf("%x", mandatory)),
+			OptionalHexMask:  strings.ToUpper(fmt.Sprintf("%x", optional)),
+		},
+	}
+	return spec, nil
+}
+
+// ToLib converts the message into lib.Iso8583Message of spec. The
+// specification is derived from the template with TemplateSpecification
+// when spec is nil.
+func (m *Message) ToLib(spec *utils.Specification) (lib.Iso8583Message, error) {
+	if generic, ok := m.Data.(lib.Iso8583Message); ok {
+		return generic, nil
+	}
+	v, tpl, err := templateValue(m.Data)
+	if err != nil {
+		return nil, err
+	}
+	if spec == nil {
+		spec, err = TemplateSpecification(m.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bitmap := make([]byte, 128)
+	for i := range bitmap {
+		bitmap[i] = '0'
+	}
+	elements := make(map[string]string)
+	for _, info := range tpl.fields {
+		field, err := info.dataField(v)
+		if err != nil {
+			return nil, err
+		}
+		if field == nil || field.IsEmpty() {
+			continue
+		}
+		if info.Index > 128 {
+			return nil, &FieldError{Index: info.Index, Type: fieldType(field), Err: errors.New("fields above 128 aren't supported by specifications")}
+		}
+		value, err := fieldValue(field)
+		if err != nil {
+			return nil, &FieldError{Index: info.Index, Type: fieldType(field), Err: err}
+		}
+		elements[strconv.Itoa(info.Index)] = string(value)
+		bitmap[info.Index-1] = '1'
+	}
+	if bytes.IndexByte(bitmap[64:], '1') >= 0 {
+		bitmap[0] = '1'
+		elements["1"] = string(bitmap[64:])
+	}
+
+	// elements are typed by the specification when they are unmarshaled
+	raw, err := json.Marshal(map[string]interface{}{
+		"mti":      m.Mti,
+		"bitmap":   string(bitmap[:64]),
+		"elements": elements,
+	})
+	if err != nil {
+		return nil, err
+	}
+	generic, err := lib.NewISO8583Message(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+// FromLib populates the message with MTI and elements of msg. Data must be
+// a pointer to template struct, which defines all elements of msg.
+func (m *Message) FromLib(msg lib.Iso8583Message) error {
+	val := reflect.ValueOf(m.Data)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return errNotPointer
+	}
+	if err := initStruct(val.Elem().Type(), val); err != nil {
+		return err
+	}
+	v, tpl, err := templateValue(m.Data)
+	if err != nil {
+		return err
+	}
+	if mti := msg.GetMti(); mti != nil {
+		m.Mti = mti.String()
+	}
+	for index, element := range msg.GetElements() {
+		if index == 1 || element == nil {
+			continue
+		}
+		info, ok := tpl.byIndex[index]
+		if !ok {
+			return &FieldError{Index: index, Err: ErrUndefinedField}
+		}
+		field, err := info.dataField(v)
+		if err != nil {
+			return err
+		}
+		if field == nil {
+			return &FieldError{Index: index, Err: ErrUndefinedField}
+		}
+		if err := setFieldValue(field, element.Value); err != nil {
+			return &FieldError{Index: index, Type: fieldType(field), Err: err}
+		}
+	}
+	return nil
+}
+
+// wireField returns a new DataField which is used on the wire by the field
+// of template, nil for fields of interface type
+func (f *fieldInfo) wireField(sf reflect.StructField) DataField {
+	if f.Type != "" {
+		return fieldTypes[f.Type]()
+	}
+	if sf.Type.Kind() == reflect.Interface {
+		return nil
+	}
+	field, _ := reflect.New(indirectType(sf.Type)).Interface().(DataField)
+	return field
+}
+
+// libEncoding sets encoding of the specification by the first field, it
+// returns override of the element when enc differs from it
+func libEncoding(global *string, set *bool, enc string) string {
+	if !*set {
+		*global, *set = enc, true
+	}
+	if *global == enc {
+		return ""
+	}
+	return enc
+}
+
+func fieldTypeName(f DataField) string {
+	if f == nil {
+		return "interface field"
+	}
+	return fieldType(f)
+}
+
+func maxLength(length, max int) int {
+	if length == -1 {
+		return max
+	}
+	return length
+}
+
+// fieldValue returns value of field as element value of pkg/lib: text of
+// characters and numbers, and bits of binary fields
+func fieldValue(field DataField) ([]byte, error) {
+	text := fieldText(field)
+	wire := field
+	if n, ok := field.(*nativeField); ok {
+		var err error
+		text, err = marshalText(reflect.Indirect(n.value), n.format)
+		if err != nil {
+			return nil, err
+		}
+		wire = n.field
+	}
+	switch wire.(type) {
+	case *Alphanumeric:
+		// Alphanumeric is padded on the left
+		text = bytes.TrimLeft(text, " ")
+	case *Binary:
+		bits := make([]byte, 0, len(text)*8)
+		for _, b := range text {
+			bits = append(bits, fmt.Sprintf("%08b", b)...)
+		}
+		text = bits
+	}
+	return text, nil
+}
+
+// setFieldValue sets element value of pkg/lib to field
+func setFieldValue(field DataField, value []byte) error {
+	wire := field
+	n, native := field.(*nativeField)
+	if native {
+		wire = n.field
+	}
+	if _, ok := wire.(*Binary); ok {
+		bits := string(value)
+		value = make([]byte, 0, len(bits)/8)
+		for i := 0; i+8 <= len(bits); i += 8 {
+			b, err := strconv.ParseUint(bits[i:i+8], 2, 8)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrInvalidValue, err)
+			}
+			value = append(value, byte(b))
+		}
+	}
+	if !native {
+		if b, ok := field.(*Binary); ok {
+			// length of the template
+			b.FixLen = -1
+		}
+		setFieldText(field, value)
+		return nil
+	}
+	v := n.value
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return unmarshalText(v, value, n.format)
+}
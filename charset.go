@@ -6,8 +6,10 @@ package iso8583
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"reflect"
 	"unicode/utf8"
 
 	"golang.org/x/text/encoding/charmap"
@@ -29,3 +31,74 @@ func UTF8ToWindows1252(input []byte) ([]byte, error) {
 	}
 	return input, nil
 }
+
+// charsetEncode converts UTF-8 value into the charset of encoder. Values
+// which aren't valid UTF-8 are taken as already encoded in the charset.
+func charsetEncode(val []byte, encoder int) ([]byte, error) {
+	switch encoder {
+	case LATIN1:
+		if !utf8.Valid(val) {
+			return val, nil
+		}
+		return transformEnconding(bytes.NewReader(val), charmap.ISO8859_1.NewEncoder())
+	case UTF8:
+		if !utf8.Valid(val) {
+			return nil, fmt.Errorf("%w: value isn't valid UTF-8", ErrInvalidValue)
+		}
+		return val, nil
+	case BINARY:
+		return val, nil
+	}
+	if !utf8.Valid(val) {
+		return val, nil
+	}
+	ret := make([]byte, 0, len(val))
+	for _, r := range string(val) {
+		b, ok := charmap.Windows1252.EncodeRune(r)
+		if !ok {
+			// bytes undefined by Windows-1252 are loaded as C1 controls
+			if r >= 0x100 || charmap.Windows1252.DecodeByte(byte(r)) != utf8.RuneError {
+				return nil, fmt.Errorf("%w: %q isn't supported by cp1252", ErrInvalidValue, r)
+			}
+			b = byte(r)
+		}
+		ret = append(ret, b)
+	}
+	return ret, nil
+}
+
+// charsetDecode converts body of a loaded field from the charset of encoder
+// into UTF-8. Every byte is decoded to a rune, so charsetEncode gives the
+// same body back.
+func charsetDecode(body []byte, encoder int) ([]byte, error) {
+	switch encoder {
+	case UTF8:
+		if !utf8.Valid(body) {
+			return nil, fmt.Errorf("%w: value isn't valid UTF-8", ErrBadRaw)
+		}
+		return body, nil
+	case BINARY:
+		return body, nil
+	}
+	ret := make([]byte, 0, len(body))
+	for _, b := range body {
+		r := rune(b)
+		if encoder != LATIN1 {
+			if d := charmap.Windows1252.DecodeByte(b); d != utf8.RuneError {
+				r = d
+			}
+		}
+		ret = append(ret, string(r)...)
+	}
+	return ret, nil
+}
+
+// isCharField reports whether field is a character field, which supports
+// charsets of ascii encoder
+func isCharField(field DataField) bool {
+	if n, ok := field.(*nativeField); ok {
+		field = n.field
+	}
+	rule, ok := fieldRules[reflect.Indirect(reflect.ValueOf(field)).Type()]
+	return ok && containsEncoder(rule.encoders, LATIN1)
+}
@@ -6,8 +6,11 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/moov-io/iso8583/pkg/utils"
@@ -202,10 +205,13 @@ func TestConvertWithInvalidData(t *testing.T) {
 }
 
 func TestValidatorWithInvalidData(t *testing.T) {
-	_, err := executeCommand(rootCmd, "validator", "--input", testInvalidFilePath)
+	output, err := executeCommand(rootCmd, "validator", "--input", testInvalidFilePath)
 	if err == nil {
 		t.Errorf("error data")
 	}
+	if !strings.Contains(output, `"field": 13`) || !strings.Contains(output, `"rule": "format"`) {
+		t.Errorf("violated rules aren't listed: %s", output)
+	}
 
 	_, err = executeCommand(rootCmd, "validator", "--input", testInvalidFilePath, "--spec", testSpecFilePath)
 	if err == nil {
@@ -333,3 +339,41 @@ func TestWebTest(t *testing.T) {
 		t.Errorf(err.Error())
 	}
 }
+
+func TestGen(t *testing.T) {
+	// specification with message types
+	spec, err := json.Marshal(utils.ISO8583DataElementsVer1987)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := ioutil.TempFile("", "spec*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.Write(spec)
+	file.Close()
+	specFile := file.Name()
+
+	output, err := executeCommand(rootCmd, "gen", "--spec", specFile, "--package", "templates")
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	if !strings.Contains(output, "package templates") || !strings.Contains(output, "type Message0100 struct") {
+		t.Errorf("unexpected generated source: %s", output)
+	}
+
+	_, err = executeCommand(rootCmd, "gen", "--spec", specFile, "--output", "output")
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+	if _, err := os.Stat("output"); err != nil {
+		t.Errorf(err.Error())
+	}
+	deleteFile()
+
+	_, err = executeCommand(rootCmd, "gen", "--spec", testSpecFilePath, "--output", "")
+	if err == nil {
+		t.Errorf("specification without message types")
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"path/filepath"
 
 	logging "github.com/moov-io/base/log"
+	"github.com/moov-io/iso8583/pkg/gen"
 	"github.com/moov-io/iso8583/pkg/lib"
 	"github.com/moov-io/iso8583/pkg/server"
 	"github.com/moov-io/iso8583/pkg/utils"
@@ -85,7 +86,17 @@ var Validate = &cobra.Command{
 			return err
 		}
 
-		return message.Validate()
+		// violated rules are printed as json list
+		err = message.Validate()
+		var report lib.ValidationReport
+		if errors.As(err, &report) {
+			output, jsonErr := json.MarshalIndent(report, "", "\t")
+			if jsonErr != nil {
+				return jsonErr
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(output))
+		}
+		return err
 	},
 }
 
@@ -209,12 +220,44 @@ var Convert = &cobra.Command{
 	},
 }
 
+var Gen = &cobra.Command{
+	Use:   "gen",
+	Short: "Generate message templates",
+	Long:  "Generate Go struct templates of the iso8583 package from the specification, one struct per message type",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pkg, err := cmd.Flags().GetString("package")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+
+		spec, err := lib.NewSpecificationWithJson(specificationBuffer)
+		if err != nil {
+			spec = &utils.ISO8583DataElementsVer1987
+		}
+		source, err := gen.Generate(spec, pkg)
+		if err != nil {
+			return err
+		}
+
+		if output == "" {
+			_, err = cmd.OutOrStdout().Write(source)
+			return err
+		}
+		return ioutil.WriteFile(output, source, 0644)
+	},
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "",
 	Short: "",
 	Long:  "",
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		isWeb := false
+		isGen := false
 		cmdNames := make([]string, 0)
 		getName := func(c *cobra.Command) {}
 		getName = func(c *cobra.Command) {
@@ -225,26 +268,32 @@ var rootCmd = &cobra.Command{
 			if c.Name() == "web" {
 				isWeb = true
 			}
+			if c.Name() == "gen" {
+				isGen = true
+			}
 			getName(c.Parent())
 		}
 		getName(cmd)
 
 		if !isWeb {
-			if messageFile == "" {
-				path, err := os.Getwd()
-				if err != nil {
-					log.Fatal(err)
+			// templates are generated from the specification only
+			if !isGen {
+				if messageFile == "" {
+					path, err := os.Getwd()
+					if err != nil {
+						log.Fatal(err)
+					}
+					messageFile = filepath.Join(path, "iso8583_message.dat")
 				}
-				messageFile = filepath.Join(path, "iso8583_message.dat")
-			}
-			_, err := os.Stat(messageFile)
-			if os.IsNotExist(err) {
-				return errors.New("invalid input file")
-			}
-			iso8583message, err = ioutil.ReadFile(messageFile)
+				_, err := os.Stat(messageFile)
+				if os.IsNotExist(err) {
+					return errors.New("invalid input file")
+				}
+				iso8583message, err = ioutil.ReadFile(messageFile)
 
-			if err != nil {
-				return err
+				if err != nil {
+					return err
+				}
 			}
 
 			if specificationFile == "" {
@@ -254,7 +303,7 @@ var rootCmd = &cobra.Command{
 				}
 				specificationFile = filepath.Join(path, "iso8583_specification.json")
 			}
-			_, err = os.Stat(specificationFile)
+			_, err := os.Stat(specificationFile)
 			if err == nil {
 				specificationBuffer, err = ioutil.ReadFile(specificationFile)
 				if err != nil {
@@ -272,6 +321,8 @@ func initRootCmd() {
 	Convert.Flags().String("format", "iso8583", "format of iso8583 message(required)")
 	Convert.MarkFlagRequired("format")
 	Print.Flags().String("format", "iso8583", "print format")
+	Gen.Flags().String("package", "messages", "package name of the generated source")
+	Gen.Flags().String("output", "", "output file of the generated source (default is stdout)")
 
 	rootCmd.SilenceUsage = true
 	rootCmd.PersistentFlags().StringVar(&messageFile, "input", "", "iso8583 message (the message types are iso8583 raw message, xml, json. default is $PWD/iso8583_message.dat)")
@@ -280,6 +331,7 @@ func initRootCmd() {
 	rootCmd.AddCommand(Convert)
 	rootCmd.AddCommand(Print)
 	rootCmd.AddCommand(Validate)
+	rootCmd.AddCommand(Gen)
 }
 
 func main() {
@@ -0,0 +1,37 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package iso8583
+
+import (
+	"github.com/indece-official/go-ebcdic"
+)
+
+// ebcdicCodePages maps EBCDIC encoders to their code pages
+var ebcdicCodePages = map[int]int{
+	EBCDIC:     ebcdic.EBCDIC037,
+	EBCDIC273:  ebcdic.EBCDIC273,
+	EBCDIC1140: ebcdic.EBCDIC1140,
+	EBCDIC1141: ebcdic.EBCDIC1141,
+}
+
+// isEBCDIC reports whether encoder is one of EBCDIC encoders
+func isEBCDIC(encoder int) bool {
+	_, ok := ebcdicCodePages[encoder]
+	return ok
+}
+
+// Encode UTF-8 text into EBCDIC with code page of encoder
+func ebcdicEncode(data []byte, encoder int) ([]byte, error) {
+	return ebcdic.Encode(string(data), ebcdicCodePages[encoder])
+}
+
+// Decode EBCDIC with code page of encoder into UTF-8 text
+func ebcdicDecode(data []byte, encoder int) ([]byte, error) {
+	str, err := ebcdic.Decode(data, ebcdicCodePages[encoder])
+	if err != nil {
+		return nil, err
+	}
+	return []byte(str), nil
+}
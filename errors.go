@@ -0,0 +1,128 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package iso8583
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var (
+	// ErrMtiRequired is given when the MTI of a message is empty
+	ErrMtiRequired = errors.New("MTI is required")
+	// ErrInvalidMti is given when the MTI isn't a 4 digit numeric value
+	ErrInvalidMti = errors.New("MTI is invalid")
+	// ErrBadMtiRaw is given when the raw data is too short for the MTI
+	ErrBadMtiRaw = errors.New("bad MTI raw data")
+	// ErrInvalidMtiEncoder is given when the MTI encoder is not supported
+	ErrInvalidMtiEncoder = errors.New("invalid encode type")
+	// ErrUnknownMti is given when no template is registered for the MTI
+	ErrUnknownMti = errors.New("no template registered for MTI")
+	// ErrUndefinedField is given when the bitmap contains a field which
+	// isn't defined in the template
+	ErrUndefinedField = errors.New("not defined in template")
+	// ErrReservedField is given when field 1 or 65 is populated, they are
+	// reserved for the secondary and tertiary bitmaps
+	ErrReservedField = errors.New("reserved for bitmap")
+	// ErrInvalidHeader is given when the header of a message can't be encoded
+	ErrInvalidHeader = errors.New("invalid header")
+	// ErrInvalidTemplate is matched by every TemplateError
+	ErrInvalidTemplate = errors.New("invalid template")
+)
+
+// FieldError is given when a data field can't be encoded or decoded. Err
+// is the cause, for example ErrBadRaw or ErrValueTooLong, and is checked
+// with errors.Is:
+//
+//	var fe *FieldError
+//	if errors.As(err, &fe) && errors.Is(fe, ErrBadRaw) {
+//		log.Printf("truncated field %d at offset %d", fe.Index, fe.Offset)
+//	}
+type FieldError struct {
+	// Index of the data field
+	Index int
+	// Offset of the data field from the beginning of the message bytes
+	Offset int
+	// Type of the data field, for example Numeric or Llvar
+	Type string
+	// Err is the cause of the error
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %d: %s", e.Index, e.Err)
+}
+
+// Unwrap returns the cause of the error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// TemplateError is given when a template struct can't be used for ISO 8583
+// messages, for example because of a malformed struct tag.
+type TemplateError struct {
+	// Field is the name of the struct field, empty when the problem isn't
+	// related to a single field
+	Field string
+	// Err is the cause of the error
+	Err error
+}
+
+func (e *TemplateError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %s", ErrInvalidTemplate, e.Err)
+	}
+	return fmt.Sprintf("%s: field %s: %s", ErrInvalidTemplate, e.Field, e.Err)
+}
+
+// Unwrap returns the cause of the error
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrInvalidTemplate
+func (e *TemplateError) Is(target error) bool {
+	return target == ErrInvalidTemplate
+}
+
+// TemplateErrors is the list of all problems of a template struct found
+// by Parser.Register
+type TemplateErrors []*TemplateError
+
+func (e TemplateErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether target is ErrInvalidTemplate
+func (e TemplateErrors) Is(target error) bool {
+	return target == ErrInvalidTemplate
+}
+
+// As finds the first TemplateError of the list
+func (e TemplateErrors) As(target interface{}) bool {
+	if t, ok := target.(**TemplateError); ok && len(e) > 0 {
+		*t = e[0]
+		return true
+	}
+	return false
+}
+
+func templateError(sf reflect.StructField, msg string) *TemplateError {
+	return &TemplateError{Field: sf.Name, Err: errors.New(msg)}
+}
+
+// fieldType returns the type name of DataField f, for example Numeric
+func fieldType(f DataField) string {
+	if n, ok := f.(*nativeField); ok {
+		f = n.field
+	}
+	return reflect.Indirect(reflect.ValueOf(f)).Type().Name()
+}
@@ -4,6 +4,11 @@
 
 package iso8583
 
+import (
+	"errors"
+	"fmt"
+)
+
 const (
 	// ASCII is ASCII encoding
 	ASCII = iota
@@ -11,25 +16,56 @@ const (
 	BCD
 	// rBCD is "right-aligned" BCD with odd length (for ex. "643" as [6 67] == "0643"), only for Numeric, Llnumeric and Lllnumeric fields
 	rBCD
+	// EBCDIC is EBCDIC encoding with code page 037
+	EBCDIC
+	// EBCDIC273 is EBCDIC encoding with code page 273
+	EBCDIC273
+	// EBCDIC1140 is EBCDIC encoding with code page 1140 (037 with euro sign)
+	EBCDIC1140
+	// EBCDIC1141 is EBCDIC encoding with code page 1141 (273 with euro sign)
+	EBCDIC1141
+	// BIN is big-endian binary length head, only for Llbin and Lllbin fields
+	BIN
+	// CP1252 is Windows-1252 charset of character fields. It's the charset
+	// of ASCII too, but isn't replaced by the Charset of Message.
+	CP1252
+	// LATIN1 is ISO 8859-1 charset of character fields
+	LATIN1
+	// UTF8 is UTF-8 charset of character fields, values must be valid UTF-8
+	UTF8
+	// BINARY keeps values of character fields as is, without charset conversion
+	BINARY
 )
 
-const (
+var (
 	// ErrInvalidEncoder is given when an invalid encoder is not supported
-	ErrInvalidEncoder string = "invalid encoder"
+	ErrInvalidEncoder = errors.New("invalid encoder")
 	// ErrInvalidLengthEncoder is given when the length of an encoder is invalid
-	ErrInvalidLengthEncoder string = "invalid length encoder"
+	ErrInvalidLengthEncoder = errors.New("invalid length encoder")
 	// ErrInvalidLengthHead is given when the length of the head is invalid
-	ErrInvalidLengthHead string = "invalid length head"
+	ErrInvalidLengthHead = errors.New("invalid length head")
 	// ErrMissingLength is given when the length of a field is missing
-	ErrMissingLength string = "missing length"
+	ErrMissingLength = errors.New("missing length")
 	// ErrValueTooLong is given when the length of the field is different from the length supplied
-	ErrValueTooLong string = "length of value is longer than definition; type=%s, def_len=%d, len=%d"
+	ErrValueTooLong = errors.New("length of value is longer than definition")
 	// ErrBadRaw is given when the raw data is malformed
-	ErrBadRaw string = "bad raw data"
+	ErrBadRaw = errors.New("bad raw data")
 	// ErrParseLengthFailed is given when the length of the raw data is invalid
-	ErrParseLengthFailed string = "parse length head failed"
+	ErrParseLengthFailed = errors.New("parse length head failed")
+	// ErrInvalidValue is given when the value can't be encoded by the field type
+	ErrInvalidValue = errors.New("invalid value")
+	// ErrInvalidBCD is given when the value can't be BCD encoded
+	ErrInvalidBCD = errors.New("invalid BCD value")
 )
 
+func errValueTooLong(typ string, defLen, l int) error {
+	return fmt.Errorf("%w; type=%s, def_len=%d, len=%d", ErrValueTooLong, typ, defLen, l)
+}
+
+func errParseLength(head []byte) error {
+	return fmt.Errorf("%w: %s", ErrParseLengthFailed, head)
+}
+
 // DataField interface for ISO 8583 fields
 type DataField interface {
 	// Byte representation of current field.
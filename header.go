@@ -0,0 +1,89 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package iso8583
+
+import (
+	"fmt"
+)
+
+// Header precedes the MTI of a message, for example the TPDU of POS
+// terminals. Custom headers implement it with their own struct.
+type Header interface {
+	// Bytes returns the header bytes emitted before the MTI
+	Bytes() ([]byte, error)
+
+	// Load reads the header from the beginning of raw. It returns the
+	// number of bytes actually read.
+	Load(raw []byte) (int, error)
+}
+
+// FixedHeader is a header of opaque bytes with fixed length
+type FixedHeader struct {
+	Length int
+	Value  []byte
+}
+
+// NewFixedHeader creates new FixedHeader of length bytes
+func NewFixedHeader(length int) *FixedHeader {
+	return &FixedHeader{Length: length}
+}
+
+// Bytes returns Value, it must have the length of the header
+func (h *FixedHeader) Bytes() ([]byte, error) {
+	if len(h.Value) != h.Length {
+		return nil, fmt.Errorf("%w: header has %d bytes, expected %d", ErrInvalidHeader, len(h.Value), h.Length)
+	}
+	return h.Value, nil
+}
+
+// Load reads Length bytes of raw into Value
+func (h *FixedHeader) Load(raw []byte) (int, error) {
+	if len(raw) < h.Length {
+		return 0, fmt.Errorf("%w: header needs %d bytes", ErrBadRaw, h.Length)
+	}
+	h.Value = append([]byte(nil), raw[:h.Length]...)
+	return h.Length, nil
+}
+
+// TPDULength is the length of TPDU header in bytes
+const TPDULength = 5
+
+// TPDU is the Transport Protocol Data Unit of POS terminals: protocol ID
+// followed by destination and source addresses
+type TPDU struct {
+	// ID is the protocol ID, usually 0x60
+	ID          byte
+	Destination [2]byte
+	Source      [2]byte
+}
+
+// Bytes encodes TPDU to 5 bytes
+func (t *TPDU) Bytes() ([]byte, error) {
+	return []byte{t.ID, t.Destination[0], t.Destination[1], t.Source[0], t.Source[1]}, nil
+}
+
+// Load decodes TPDU from the first 5 bytes of raw
+func (t *TPDU) Load(raw []byte) (int, error) {
+	if len(raw) < TPDULength {
+		return 0, fmt.Errorf("%w: TPDU needs %d bytes", ErrBadRaw, TPDULength)
+	}
+	t.ID = raw[0]
+	copy(t.Destination[:], raw[1:3])
+	copy(t.Source[:], raw[3:5])
+	return TPDULength, nil
+}
+
+// Swap exchanges destination and source addresses
+func (t *TPDU) Swap() {
+	t.Destination, t.Source = t.Source, t.Destination
+}
+
+// Response returns TPDU of the response to the message with t, its
+// destination and source addresses are swapped
+func (t *TPDU) Response() *TPDU {
+	resp := *t
+	resp.Swap()
+	return &resp
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package iso8583
+
+import (
+	"fmt"
+
+	"github.com/moov-io/iso8583/pkg/lib"
+)
+
+// BeforePacker is implemented by templates which fill fields of the
+// message, for example the transmission date and time, before Bytes
+type BeforePacker interface {
+	BeforePack(m *Message) error
+}
+
+// AfterUnpacker is implemented by templates which process the fields of
+// the message after Load, for example to check invariants between fields
+type AfterUnpacker interface {
+	AfterUnpack(m *Message) error
+}
+
+// Validator is implemented by templates which check their fields. It's
+// called by Bytes after BeforePack and by Load after AfterUnpack.
+type Validator interface {
+	Validate() error
+}
+
+// HookError is given when a hook of the template fails
+type HookError struct {
+	// Mti of the message
+	Mti string
+	// Hook is the failed hook, BeforePack, AfterUnpack or Validate
+	Hook string
+	// Err is the error returned by the hook
+	Err error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("MTI %s: %s: %s", e.Mti, e.Hook, e.Err)
+}
+
+// Unwrap returns the error returned by the hook
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// beforePack calls BeforePack and Validate hooks of the template
+func (m *Message) beforePack() error {
+	if _, ok := m.Data.(lib.Iso8583Message); ok {
+		return nil
+	}
+	if h, ok := m.Data.(BeforePacker); ok {
+		if err := h.BeforePack(m); err != nil {
+			return &HookError{m.Mti, "BeforePack", err}
+		}
+	}
+	return m.validate()
+}
+
+// afterUnpack calls AfterUnpack and Validate hooks of the template
+func (m *Message) afterUnpack() error {
+	if h, ok := m.Data.(AfterUnpacker); ok {
+		if err := h.AfterUnpack(m); err != nil {
+			return &HookError{m.Mti, "AfterUnpack", err}
+		}
+	}
+	return m.validate()
+}
+
+func (m *Message) validate() error {
+	if v, ok := m.Data.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return &HookError{m.Mti, "Validate", err}
+		}
+	}
+	return nil
+}
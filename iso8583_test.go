@@ -6,9 +6,19 @@ package iso8583
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/moov-io/iso8583/pkg/lib"
+	"github.com/moov-io/iso8583/pkg/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -70,7 +80,7 @@ type TestISO2 struct {
 	F58 *Lllvar       `field:"58" length:"255" encode:"ascii,ascii"`
 	F59 *Llvar        `field:"59" length:"255" encode:"rbcd,ascii"`
 	F60 *Lllnumeric   `field:"60" length:"999" encode:"bcd,ascii"`
-	F61 *Lllnumeric   `field:"60" length:"999" encode:"bcd,rbcd"`
+	F61 *Lllnumeric   `field:"61" length:"999" encode:"bcd,rbcd"`
 	F63 *Lllnumeric   `field:"63" length:"999" encode:"rbcd,bcd"`
 	F64 *Binary       `field:"64" length:"32"`
 }
@@ -101,7 +111,7 @@ func TestEncode(t *testing.T) {
 		F120: NewLllnumeric("Another test text"),
 	}
 
-	iso := Message{"0100", ASCII, true, false, data}
+	iso := Message{Mti: "0100", SecondBitmap: true, Data: data}
 
 	res, err := iso.Bytes()
 
@@ -121,7 +131,7 @@ func TestDecode(t *testing.T) {
 	input := []byte{48, 49, 48, 48, 242, 60, 36, 129, 40, 224, 152, 0, 0, 0, 0, 0, 0, 0, 1, 0, 49, 54, 52, 50, 55, 54, 53, 53, 53, 53, 53, 53, 53, 53, 53, 53, 53, 53, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 55, 55, 55, 48, 48, 48, 55, 48, 49, 49, 49, 49, 56, 52, 52, 48, 48, 48, 49, 50, 51, 49, 51, 49, 56, 52, 52, 48, 55, 48, 49, 49, 57, 48, 50, 6, 67, 57, 48, 49, 48, 50, 48, 54, 49, 50, 51, 52, 53, 54, 51, 55, 52, 50, 55, 54, 53, 53, 53, 53, 53, 53, 53, 53, 53, 53, 53, 53, 61, 49, 50, 51, 52, 53, 54, 55, 56, 57, 48, 49, 50, 51, 52, 53, 54, 55, 56, 57, 48, 57, 56, 55, 54, 53, 52, 51, 50, 49, 48, 48, 49, 48, 48, 48, 48, 48, 51, 50, 49, 49, 50, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 51, 52, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 84, 101, 115, 116, 32, 116, 101, 120, 116, 100, 48, 1, 2, 3, 4, 5, 6, 7, 8, 49, 50, 51, 52, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 49, 55, 65, 110, 111, 116, 104, 101, 114, 32, 116, 101, 115, 116, 32, 116, 101, 120, 116}
 
 	// init empty iso message struct
-	iso := Message{"", ASCII, true, false, newDataIso()}
+	iso := Message{SecondBitmap: true, Data: newDataIso()}
 
 	// parse data from bytes to iso struct
 	err := iso.Load(input)
@@ -214,7 +224,7 @@ func TestEncodeASCIIBitmap(t *testing.T) {
 		F120: NewLllnumeric("Another test text"),
 	}
 
-	iso := Message{"0100", ASCII, true, true, data}
+	iso := Message{Mti: "0100", SecondBitmap: true, ASCIIBitmap: true, Data: data}
 
 	res, err := iso.Bytes()
 
@@ -230,7 +240,7 @@ func TestEncodeASCIIBitmap(t *testing.T) {
 func TestDecodeASCIIBitmap(t *testing.T) {
 	input := []byte("0100F23C248128E098000000000000000100164276555555555555000000000000077700070111184400012313184407011902\x06C9010206123456374276555555555555=1234567890123456789098765432100100000321120000000000034                               Test textd0\x01\x02\x03\x04\x05\x06\x07\x081234000000000000017Another test text")
 
-	iso := Message{"", ASCII, true, true, newDataIso()}
+	iso := Message{SecondBitmap: true, ASCIIBitmap: true, Data: newDataIso()}
 	err := iso.Load(input)
 
 	assert.NoError(t, err, "ISO Decode error:")
@@ -261,6 +271,75 @@ func TestDecodeASCIIBitmap(t *testing.T) {
 	assert.Equal(t, "Another test text", resultFields.F120.Value)
 }
 
+func TestThirdBitmap(t *testing.T) {
+	type testIso struct {
+		F2   *Numeric      `field:"2" length:"6"`
+		F70  *Numeric      `field:"70" length:"3"`
+		F130 *Alphanumeric `field:"130" length:"5"`
+	}
+
+	data := &testIso{
+		F2:   NewNumeric("123456"),
+		F70:  NewNumeric("301"),
+		F130: NewAlphanumeric("hello"),
+	}
+
+	// SecondBitmap is derived from the highest populated field
+	iso := NewMessage("0800", data)
+	res, err := iso.Bytes()
+	assert.NoError(t, err)
+	assert.True(t, iso.SecondBitmap)
+
+	expected := []byte("0800\xc0\x00\x00\x00\x00\x00\x00\x00\x84\x00\x00\x00\x00\x00\x00\x00\x40\x00\x00\x00\x00\x00\x00\x00123456301hello")
+	assert.Equal(t, expected, res)
+
+	iso2 := NewMessage("", &testIso{NewNumeric(""), NewNumeric(""), NewAlphanumeric("")})
+	err = iso2.Load(res)
+	assert.NoError(t, err)
+	assert.Equal(t, iso, iso2)
+
+	// ASCII bitmap
+	iso = &Message{Mti: "0800", ASCIIBitmap: true, Data: data}
+	res, err = iso.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "0800C00000000000000084000000000000004000000000000000123456301hello", string(res))
+
+	iso2 = &Message{ASCIIBitmap: true, Data: &testIso{NewNumeric(""), NewNumeric(""), NewAlphanumeric("")}}
+	err = iso2.Load(res)
+	assert.NoError(t, err)
+	assert.Equal(t, iso, iso2)
+
+	// without fields above 128 only two bitmaps are sent
+	data.F130.Value = ""
+	iso = NewMessage("0800", data)
+	res, err = iso.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("0800\xc0\x00\x00\x00\x00\x00\x00\x00\x04\x00\x00\x00\x00\x00\x00\x00123456301"), res)
+
+	// bitmap is too short
+	err = NewMessage("", data).Load([]byte("0800\xc0\x00\x00\x00\x00\x00\x00\x00\x84\x00"))
+	assert.EqualError(t, err, "bad raw data")
+
+	// field 65 is the tertiary bitmap indicator
+	type testIso65 struct {
+		F2  *Numeric      `field:"2" length:"6"`
+		F65 *Alphanumeric `field:"65" length:"8"`
+		F70 *Numeric      `field:"70" length:"3"`
+	}
+	_, err = NewMessage("0800", &testIso65{NewNumeric("123456"), NewAlphanumeric("12345678"), NewNumeric("301")}).Bytes()
+	assert.True(t, errors.Is(err, ErrReservedField))
+	assert.EqualError(t, err, "field 65: reserved for bitmap")
+
+	// without field 65 the message round-trips
+	res, err = NewMessage("0800", &testIso65{F2: NewNumeric("123456"), F70: NewNumeric("301")}).Bytes()
+	assert.NoError(t, err)
+	result := &testIso65{}
+	assert.NoError(t, Unmarshal(res, result))
+	assert.Equal(t, "123456", result.F2.Value)
+	assert.Equal(t, "", result.F65.Value)
+	assert.Equal(t, "301", result.F70.Value)
+}
+
 func TestEncodeDecode(t *testing.T) {
 
 	data := &TestISO2{
@@ -332,7 +411,7 @@ func TestFieldNumericEncodeErrors(t *testing.T) {
 
 	_, err := iso.Bytes()
 
-	assert.EqualError(t, err, "invalid encoder")
+	assert.EqualError(t, err, "field 2: invalid encoder")
 
 	type test2 struct {
 		F2 *Numeric `field:"2"`
@@ -346,7 +425,7 @@ func TestFieldNumericEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "missing length")
+	assert.EqualError(t, err, "field 2: missing length")
 
 	type test3 struct {
 		F2 *Numeric `field:"2" length:"3"`
@@ -360,7 +439,7 @@ func TestFieldNumericEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "length of value is longer than definition; type=Numeric, def_len=3, len=6")
+	assert.EqualError(t, err, "field 2: length of value is longer than definition; type=Numeric, def_len=3, len=6")
 }
 
 func TestFieldAlphanumericEncodeErrors(t *testing.T) {
@@ -377,7 +456,7 @@ func TestFieldAlphanumericEncodeErrors(t *testing.T) {
 
 	_, err := iso.Bytes()
 
-	assert.EqualError(t, err, "missing length")
+	assert.EqualError(t, err, "field 2: missing length")
 
 	type test2 struct {
 		F2 *Alphanumeric `field:"2" length:"3"`
@@ -391,7 +470,7 @@ func TestFieldAlphanumericEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "length of value is longer than definition; type=Alphanumeric, def_len=3, len=6")
+	assert.EqualError(t, err, "field 2: length of value is longer than definition; type=Alphanumeric, def_len=3, len=6")
 }
 
 func TestFieldBinaryEncodeErrors(t *testing.T) {
@@ -408,7 +487,7 @@ func TestFieldBinaryEncodeErrors(t *testing.T) {
 
 	_, err := iso.Bytes()
 
-	assert.EqualError(t, err, "missing length")
+	assert.EqualError(t, err, "field 2: missing length")
 
 	type test2 struct {
 		F2 *Binary `field:"2" length:"3"`
@@ -422,7 +501,7 @@ func TestFieldBinaryEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "length of value is longer than definition; type=Binary, def_len=3, len=6")
+	assert.EqualError(t, err, "field 2: length of value is longer than definition; type=Binary, def_len=3, len=6")
 }
 
 func TestFieldLlnumericEncodeErrors(t *testing.T) {
@@ -439,7 +518,7 @@ func TestFieldLlnumericEncodeErrors(t *testing.T) {
 
 	_, err := iso.Bytes()
 
-	assert.EqualError(t, err, "invalid encoder")
+	assert.EqualError(t, err, "field 2: invalid encoder")
 
 	type test2 struct {
 		F2 *Llnumeric `field:"2" length:"3"`
@@ -453,7 +532,7 @@ func TestFieldLlnumericEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "length of value is longer than definition; type=Llnumeric, def_len=3, len=6")
+	assert.EqualError(t, err, "field 2: length of value is longer than definition; type=Llnumeric, def_len=3, len=6")
 
 	type test3 struct {
 		F2 *Llnumeric `field:"2" encode:"ascii,ascii"`
@@ -467,7 +546,7 @@ func TestFieldLlnumericEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "invalid length head")
+	assert.EqualError(t, err, "field 2: invalid length head")
 
 	type test4 struct {
 		F2 *Llnumeric `field:"2" length:"100" encode:"bcd,ascii"`
@@ -481,7 +560,7 @@ func TestFieldLlnumericEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "invalid length head")
+	assert.EqualError(t, err, "field 2: invalid length head")
 
 	type test5 struct {
 		F2 *Llnumeric `field:"2" length:"6" encode:"test,ascii"`
@@ -495,7 +574,7 @@ func TestFieldLlnumericEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "invalid length encoder")
+	assert.EqualError(t, err, "field 2: invalid length encoder")
 }
 
 func TestFieldLllnumericEncodeErrors(t *testing.T) {
@@ -512,7 +591,7 @@ func TestFieldLllnumericEncodeErrors(t *testing.T) {
 
 	_, err := iso.Bytes()
 
-	assert.EqualError(t, err, "invalid encoder")
+	assert.EqualError(t, err, "field 2: invalid encoder")
 
 	type test2 struct {
 		F2 *Lllnumeric `field:"2" length:"3"`
@@ -526,7 +605,7 @@ func TestFieldLllnumericEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "length of value is longer than definition; type=Lllnumeric, def_len=3, len=6")
+	assert.EqualError(t, err, "field 2: length of value is longer than definition; type=Lllnumeric, def_len=3, len=6")
 
 	type test3 struct {
 		F2 *Lllnumeric `field:"2" encode:"ascii,ascii"`
@@ -540,7 +619,7 @@ func TestFieldLllnumericEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "invalid length head")
+	assert.EqualError(t, err, "field 2: invalid length head")
 
 	type test4 struct {
 		F2 *Lllnumeric `field:"2" length:"1000" encode:"bcd,ascii"`
@@ -554,7 +633,7 @@ func TestFieldLllnumericEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "invalid length head")
+	assert.EqualError(t, err, "field 2: invalid length head")
 
 	type test5 struct {
 		F2 *Lllnumeric `field:"2" length:"6" encode:"test,ascii"`
@@ -568,7 +647,7 @@ func TestFieldLllnumericEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "invalid length encoder")
+	assert.EqualError(t, err, "field 2: invalid length encoder")
 }
 
 func TestFieldLlvarEncodeErrors(t *testing.T) {
@@ -585,7 +664,7 @@ func TestFieldLlvarEncodeErrors(t *testing.T) {
 
 	_, err := iso.Bytes()
 
-	assert.EqualError(t, err, "invalid encoder")
+	assert.EqualError(t, err, "field 2: invalid encoder")
 
 	type test2 struct {
 		F2 *Llvar `field:"2" length:"3"`
@@ -599,7 +678,7 @@ func TestFieldLlvarEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "length of value is longer than definition; type=Llvar, def_len=3, len=6")
+	assert.EqualError(t, err, "field 2: length of value is longer than definition; type=Llvar, def_len=3, len=6")
 
 	type test3 struct {
 		F2 *Llvar `field:"2" encode:"ascii,ascii"`
@@ -613,7 +692,7 @@ func TestFieldLlvarEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "invalid length head")
+	assert.EqualError(t, err, "field 2: invalid length head")
 
 	type test4 struct {
 		F2 *Llvar `field:"2" length:"100" encode:"bcd,ascii"`
@@ -627,7 +706,7 @@ func TestFieldLlvarEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "invalid length head")
+	assert.EqualError(t, err, "field 2: invalid length head")
 
 	type test5 struct {
 		F2 *Llvar `field:"2" length:"6" encode:"test,ascii"`
@@ -641,7 +720,7 @@ func TestFieldLlvarEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "invalid length encoder")
+	assert.EqualError(t, err, "field 2: invalid length encoder")
 }
 
 func TestFieldLllvarEncodeErrors(t *testing.T) {
@@ -658,7 +737,7 @@ func TestFieldLllvarEncodeErrors(t *testing.T) {
 
 	_, err := iso.Bytes()
 
-	assert.EqualError(t, err, "invalid encoder")
+	assert.EqualError(t, err, "field 2: invalid encoder")
 
 	type test2 struct {
 		F2 *Lllvar `field:"2" length:"3"`
@@ -672,7 +751,7 @@ func TestFieldLllvarEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "length of value is longer than definition; type=Lllvar, def_len=3, len=6")
+	assert.EqualError(t, err, "field 2: length of value is longer than definition; type=Lllvar, def_len=3, len=6")
 
 	type test3 struct {
 		F2 *Lllvar `field:"2" encode:"ascii,ascii"`
@@ -686,7 +765,7 @@ func TestFieldLllvarEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "invalid length head")
+	assert.EqualError(t, err, "field 2: invalid length head")
 
 	type test4 struct {
 		F2 *Lllvar `field:"2" length:"1000" encode:"bcd,ascii"`
@@ -700,7 +779,7 @@ func TestFieldLllvarEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "invalid length head")
+	assert.EqualError(t, err, "field 2: invalid length head")
 
 	type test5 struct {
 		F2 *Lllvar `field:"2" length:"6" encode:"test,ascii"`
@@ -714,7 +793,7 @@ func TestFieldLllvarEncodeErrors(t *testing.T) {
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "invalid length encoder")
+	assert.EqualError(t, err, "field 2: invalid length encoder")
 }
 
 func TestFieldNumericDecodeErrors(t *testing.T) {
@@ -1469,11 +1548,12 @@ func TestParserErrors(t *testing.T) {
 
 	err := parser.Register("0100", nil)
 
-	assert.EqualError(t, err, "Critical error:reflect: call of reflect.Value.Type on zero Value")
+	assert.EqualError(t, err, "invalid template: data must be a struct")
+	assert.True(t, errors.Is(err, ErrInvalidTemplate))
 
 	err = parser.Register("1", newDataIso())
 
-	assert.EqualError(t, err, "MTI must be a 4 digit numeric field")
+	assert.EqualError(t, err, "MTI is invalid: must be a 4 digit numeric field")
 
 	_, err = parser.Parse([]byte{0})
 
@@ -1502,11 +1582,120 @@ func TestParserErrors(t *testing.T) {
 
 	assert.EqualError(t, err, "field 2: bad raw data")
 
-	parser.messages["0100"] = nil
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe))
+	assert.Equal(t, 2, fe.Index)
+	assert.Equal(t, 20, fe.Offset)
+	assert.Equal(t, "Llnumeric", fe.Type)
+	assert.True(t, errors.Is(err, ErrBadRaw))
+
+	_, err = parser.Parse([]byte("0200"))
+
+	assert.True(t, errors.Is(err, ErrUnknownMti))
+}
+
+func TestRegisterValidation(t *testing.T) {
+	type test1 struct {
+		F2  *Llnumeric    `field:"2" length:"19" encode:"bcd,rbcd"`
+		F3  *Numeric      `field:"3"`
+		F4  *Numeric      `field:"4" length:"12" encode:"ascii,bcd"`
+		F5  *Alphanumeric `field:"5" length:"8" encode:"bcd"`
+		F6  *Llvar        `field:"6" length:"255"`
+		F7  *Lllvar       `field:"7" length:"99" encode:"test,ascii"`
+		F8  *Binary       `field:"8" length:"0"`
+		F9  *Numeric      `field:"2" length:"6"`
+		F10 string        `field:"10" length:"6"`
+		F11 *Numeric      `field:"abc" length:"6"`
+		F12 *Numeric      `field:"65" length:"6"`
+	}
+
+	parser := Parser{}
+	err := parser.Register("0100", &test1{})
+
+	var errs TemplateErrors
+	assert.True(t, errors.As(err, &errs))
+	assert.True(t, errors.Is(err, ErrInvalidTemplate))
+	assert.Equal(t, []string{
+		"invalid template: field F3: length is required by Numeric",
+		"invalid template: field F4: length encoding isn't supported by Numeric",
+		"invalid template: field F5: encoding bcd isn't supported by Alphanumeric",
+		"invalid template: field F6: length 255 exceeds maximum 99 of Llvar",
+		"invalid template: field F7: length encoding test isn't supported by Lllvar",
+		"invalid template: field F8: value of length must be positive",
+		"invalid template: field F9: field 2 is already defined by F2",
+		"invalid template: field F10: field must be Iso8583Type",
+		"invalid template: field F11: value of field must be numeric",
+		"invalid template: field F12: field 65 is reserved for tertiary bitmap",
+	}, templateErrorStrings(errs))
+
+	// problems of field types are reported when the template is usable
+	type test2 struct {
+		F2  *Llnumeric    `field:"2" length:"19" encode:"bcd,rbcd"`
+		F3  *Numeric      `field:"3"`
+		F4  *Numeric      `field:"4" length:"12" encode:"ascii,bcd"`
+		F5  *Alphanumeric `field:"5" length:"8" encode:"bcd"`
+		F6  *Llvar        `field:"6" length:"255"`
+		F7  *Lllvar       `field:"7" length:"99" encode:"test,ascii"`
+		F8  *Binary       `field:"8" length:"0"`
+		F12 *Numeric      `field:"65" length:"6"`
+		F13 int64         `field:"13" type:"llnumeric,omitempty" length:"100"`
+	}
+
+	err = parser.Register("0100", &test2{})
+
+	assert.True(t, errors.As(err, &errs))
+	assert.Equal(t, []string{
+		"invalid template: field F3: length is required by Numeric",
+		"invalid template: field F4: length encoding isn't supported by Numeric",
+		"invalid template: field F5: encoding bcd isn't supported by Alphanumeric",
+		"invalid template: field F6: length 255 exceeds maximum 99 of Llvar",
+		"invalid template: field F7: length encoding test isn't supported by Lllvar",
+		"invalid template: field F8: value of length must be positive",
+		"invalid template: field F12: field 65 is reserved for tertiary bitmap",
+		"invalid template: field F13: length 100 exceeds maximum 99 of Llnumeric",
+	}, templateErrorStrings(errs))
+
+	_, err = parser.Parse([]byte("0100"))
+	assert.True(t, errors.Is(err, ErrUnknownMti))
+
+	var te *TemplateError
+	assert.False(t, errors.As(err, &te))
+	assert.True(t, errors.As(parser.Register("0100", &test2{}), &te))
+	assert.Equal(t, "F3", te.Field)
+}
+
+func templateErrorStrings(errs TemplateErrors) []string {
+	ret := make([]string, len(errs))
+	for i, err := range errs {
+		ret[i] = err.Error()
+	}
+	return ret
+}
 
-	_, err = parser.Parse(input)
+func TestUnexportedTemplateField(t *testing.T) {
+	type testIso struct {
+		F2 *Llnumeric `field:"2" length:"19"`
+		f3 *Numeric   `field:"3" length:"6"`
+		f4 int64      `field:"4" type:"numeric" length:"12"`
+	}
+
+	parser := Parser{}
+	err := parser.Register("0100", &testIso{})
+
+	var errs TemplateErrors
+	assert.True(t, errors.As(err, &errs))
+	assert.Equal(t, []string{
+		"invalid template: field f3: field must be exported",
+		"invalid template: field f4: field must be exported",
+	}, templateErrorStrings(errs))
 
-	assert.EqualError(t, err, "Critical error:reflect: New(nil)")
+	iso := Message{Mti: "0100", Data: &testIso{F2: NewLlnumeric("1234"), f3: NewNumeric("123456"), f4: 1}}
+	_, err = iso.Bytes()
+	assert.True(t, errors.Is(err, ErrInvalidTemplate))
+
+	input := []byte{48, 49, 48, 48, 64, 0, 0, 0, 0, 0, 0, 0, 48, 52, 49, 50, 51, 52}
+	err = (&Message{Data: &testIso{}}).Load(input)
+	assert.True(t, errors.Is(err, ErrInvalidTemplate))
 }
 
 func TestParser(t *testing.T) {
@@ -1590,23 +1779,23 @@ func TestMessage(t *testing.T) {
 		AB *Llnumeric `field:"ab" length:"19"`
 	}
 
-	iso := Message{"", ASCII, true, false, TestIso{*newDataIso(), NewLlnumeric("")}}
+	iso := Message{SecondBitmap: true, Data: TestIso{*newDataIso(), NewLlnumeric("")}}
 
 	input := []byte{48, 49, 48, 48, 114, 60, 36, 129, 40, 224, 152, 0, 49, 54, 52, 50, 55, 54, 53, 53, 53, 53, 53, 53, 53, 53, 53, 53, 53, 53, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 55, 55, 55, 48, 48, 48, 55, 48, 49, 49, 49, 49, 56, 52, 52, 48, 48, 48, 49, 50, 51, 49, 51, 49, 56, 52, 52, 48, 55, 48, 49, 49, 57, 48, 50, 6, 67, 57, 48, 49, 48, 50, 48, 54, 49, 50, 51, 52, 53, 54, 51, 55, 52, 50, 55, 54, 53, 53, 53, 53, 53, 53, 53, 53, 53, 53, 53, 53, 61, 49, 50, 51, 52, 53, 54, 55, 56, 57, 48, 49, 50, 51, 52, 53, 54, 55, 56, 57, 48, 57, 56, 55, 54, 53, 52, 51, 50, 49, 48, 48, 49, 48, 48, 48, 48, 48, 51, 50, 49, 49, 50, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 51, 52, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 84, 101, 115, 116, 32, 116, 101, 120, 116, 100, 48, 1, 2, 3, 4, 5, 6, 7, 8, 49, 50, 51, 52, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48}
 
 	err := iso.Load(input)
 
-	assert.EqualError(t, err, "Critical error:value of field must be numeric")
+	assert.EqualError(t, err, "invalid template: field AB: value of field must be numeric")
 
 	type TestIso2 struct {
 		F2 *Llnumeric `field:"2" length:"19"`
 	}
 
-	iso = Message{"", ASCII, true, false, TestIso2{}}
+	iso = Message{SecondBitmap: true, Data: TestIso2{}}
 
 	err = iso.Load(input)
 
-	assert.EqualError(t, err, "field 2 not defined")
+	assert.EqualError(t, err, "field 2: not defined in template")
 
 }
 
@@ -1615,7 +1804,7 @@ func TestMTIError(t *testing.T) {
 		F2: NewLlnumeric("4276555555555555"),
 	}
 
-	iso := Message{"01000", ASCII, true, false, data}
+	iso := Message{Mti: "01000", SecondBitmap: true, Data: data}
 
 	_, err := iso.Bytes()
 
@@ -1633,13 +1822,13 @@ func TestMTIError(t *testing.T) {
 
 	assert.EqualError(t, err, "MTI is required")
 
-	iso = Message{"0100", BCD, true, false, data}
+	iso = Message{Mti: "0100", MtiEncode: BCD, SecondBitmap: true, Data: data}
 
 	res, err := iso.Bytes()
 
 	assert.Empty(t, err)
 
-	iso = Message{"", BCD, true, false, data}
+	iso = Message{MtiEncode: BCD, SecondBitmap: true, Data: data}
 
 	err = iso.Load(res[0:1])
 
@@ -1658,13 +1847,13 @@ func TestMTIBCD(t *testing.T) {
 		F2: NewLlnumeric("4276555555555555"),
 	}
 
-	iso := Message{"0100", BCD, true, false, data}
+	iso := Message{Mti: "0100", MtiEncode: BCD, SecondBitmap: true, Data: data}
 
 	res, err := iso.Bytes()
 
 	assert.Empty(t, err)
 
-	iso2 := Message{"0100", BCD, true, false, data}
+	iso2 := Message{Mti: "0100", MtiEncode: BCD, SecondBitmap: true, Data: data}
 
 	err = iso2.Load(res)
 
@@ -1682,11 +1871,11 @@ func TestParseFieldsErrors(t *testing.T) {
 		F2: NewLlnumeric("4276555555555555"),
 	}
 
-	iso := Message{"0100", BCD, true, false, data1}
+	iso := Message{Mti: "0100", MtiEncode: BCD, SecondBitmap: true, Data: data1}
 
 	_, err := iso.Bytes()
 
-	assert.EqualError(t, err, "Critical error:value of field must be numeric")
+	assert.EqualError(t, err, "invalid template: field F2: value of field must be numeric")
 
 	type test2 struct {
 		F2 *Llnumeric `field:"2" length:"abc"`
@@ -1696,11 +1885,11 @@ func TestParseFieldsErrors(t *testing.T) {
 		F2: NewLlnumeric("4276555555555555"),
 	}
 
-	iso = Message{"0100", BCD, true, false, data2}
+	iso = Message{Mti: "0100", MtiEncode: BCD, SecondBitmap: true, Data: data2}
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "Critical error:value of length must be numeric")
+	assert.EqualError(t, err, "invalid template: field F2: value of length must be numeric")
 
 	type test3 struct {
 		F2 string `field:"2" length:"2"`
@@ -1710,17 +1899,67 @@ func TestParseFieldsErrors(t *testing.T) {
 		F2: string("123abc"),
 	}
 
-	iso = Message{"0100", BCD, true, false, data3}
+	iso = Message{Mti: "0100", MtiEncode: BCD, SecondBitmap: true, Data: data3}
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "Critical error:field must be Iso8583Type")
+	assert.EqualError(t, err, "invalid template: field F2: field must be Iso8583Type")
 
-	iso = Message{"0100", BCD, true, false, nil}
+	iso = Message{Mti: "0100", MtiEncode: BCD, SecondBitmap: true, Data: nil}
 
 	_, err = iso.Bytes()
 
-	assert.EqualError(t, err, "Critical error:data must be a struct")
+	assert.EqualError(t, err, "invalid template: data must be a struct")
+}
+
+func TestFieldErrors(t *testing.T) {
+	type test1 struct {
+		F2 *Llnumeric `field:"2" length:"19"`
+		F3 *Numeric   `field:"3" length:"3"`
+		F4 *Numeric   `field:"4" length:"4" encode:"bcd"`
+	}
+
+	_, err := NewMessage("0100", &test1{F2: NewLlnumeric("42"), F3: NewNumeric("1234")}).Bytes()
+
+	var fe *FieldError
+	assert.True(t, errors.As(err, &fe))
+	assert.Equal(t, 3, fe.Index)
+	assert.Equal(t, 16, fe.Offset)
+	assert.Equal(t, "Numeric", fe.Type)
+	assert.True(t, errors.Is(err, ErrValueTooLong))
+	assert.EqualError(t, err, "field 3: length of value is longer than definition; type=Numeric, def_len=3, len=4")
+
+	_, err = NewMessage("0100", &test1{F4: NewNumeric("12=4")}).Bytes()
+
+	assert.True(t, errors.Is(err, ErrInvalidBCD))
+
+	// length head of field 2 is truncated
+	err = Unmarshal([]byte("0100\x40\x00\x00\x00\x00\x00\x00\x001"), &test1{})
+
+	assert.True(t, errors.As(err, &fe))
+	assert.Equal(t, 12, fe.Offset)
+	assert.True(t, errors.Is(err, ErrBadRaw))
+
+	err = NewMessage("0100", &test1{}).Load([]byte("01"))
+
+	assert.Equal(t, ErrBadMtiRaw, err)
+
+	type test2 struct {
+		F2 interface{} `field:"2" length:"19"`
+	}
+
+	_, err = NewMessage("0100", &test2{F2: "42"}).Bytes()
+
+	assert.True(t, errors.Is(err, ErrInvalidTemplate))
+
+	parser := Parser{}
+	err = parser.Register("0100", &struct {
+		F2 int `field:"2" length:"2"`
+	}{})
+
+	var te *TemplateError
+	assert.True(t, errors.As(err, &te))
+	assert.Equal(t, "F2", te.Field)
 }
 
 // newDataIso creates DataIso
@@ -1794,8 +2033,981 @@ func TestWindows1252(t *testing.T) {
 	err = iso.Load(result)
 	assert.NoError(t, err)
 	resultFields := iso.Data.(*testIso)
-	assert.Equal(t, resultFields.F2.Value, []byte("gar\xe7on!"))
-	assert.Equal(t, resultFields.F3.Value, []byte("cora\xe7\xe3o"))
-	assert.Equal(t, resultFields.F4.Value, "   solu\xe7\xe3o")
-	assert.Equal(t, resultFields.F5.Value, []byte("bota mais feij\xe3o ai meu irm\xe3o"))
+	assert.Equal(t, resultFields.F2.Value, []byte("garçon!"))
+	assert.Equal(t, resultFields.F3.Value, []byte("coração"))
+	assert.Equal(t, resultFields.F4.Value, "   solução")
+	assert.Equal(t, resultFields.F5.Value, []byte("bota mais feijão ai meu irmão"))
+}
+
+func TestCharsets(t *testing.T) {
+	type testIso struct {
+		F2 *Llvar        `field:"2" length:"10" encode:"ascii"`
+		F3 *Alphanumeric `field:"3" length:"3"`
+		F4 *Lllvar       `field:"4" length:"20" encode:"cp1252"`
+	}
+
+	// conversion is applied to the field body only, so the offset of the
+	// following field isn't shifted by multibyte runes
+	raw := []byte("0100" + "\x60\x00\x00\x00\x00\x00\x00\x00" + "02\xc3\xa7" + "abc")
+	iso := NewMessage("", &testIso{F2: NewLlvar(nil), F3: NewAlphanumeric("")})
+	assert.NoError(t, iso.Load(raw))
+	loaded := iso.Data.(*testIso)
+	assert.Equal(t, []byte("Ã§"), loaded.F2.Value)
+	assert.Equal(t, "abc", loaded.F3.Value)
+
+	data := &testIso{
+		F2: NewLlvar([]byte("garçon")),
+		F3: NewAlphanumeric("ç"),
+		F4: NewLllvar([]byte("ação")),
+	}
+	tests := []struct {
+		charset  int
+		expected string
+	}{
+		{ASCII, "06gar\xe7on" + "  \xe7" + "004a\xe7\xe3o"},
+		{LATIN1, "06gar\xe7on" + "  \xe7" + "004a\xe7\xe3o"},
+		{UTF8, "07gar\xc3\xa7on" + " \xc3\xa7" + "004a\xe7\xe3o"},
+		{BINARY, "07gar\xc3\xa7on" + " \xc3\xa7" + "004a\xe7\xe3o"},
+	}
+	for _, tt := range tests {
+		iso := NewMessage("0100", data)
+		iso.Charset = tt.charset
+		b, err := iso.Bytes()
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, "0100\x70\x00\x00\x00\x00\x00\x00\x00"+tt.expected, string(b))
+
+		parser := Parser{Charset: tt.charset}
+		assert.NoError(t, parser.Register("0100", &testIso{}))
+		parsed, err := parser.Parse(b)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, tt.charset, parsed.Charset)
+		assert.Equal(t, []byte("ação"), parsed.Data.(*testIso).F4.Value)
+	}
+
+	iso = NewMessage("0100", &testIso{F2: NewLlvar([]byte("\xe7"))})
+	iso.Charset = UTF8
+	_, err := iso.Bytes()
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+	var fe *FieldError
+	if assert.True(t, errors.As(err, &fe)) {
+		assert.Equal(t, 2, fe.Index)
+	}
+
+	iso = NewMessage("", &testIso{F2: NewLlvar(nil)})
+	iso.Charset = UTF8
+	err = iso.Load([]byte("0100" + "\x40\x00\x00\x00\x00\x00\x00\x00" + "01\xe7"))
+	assert.True(t, errors.Is(err, ErrBadRaw))
+}
+
+func TestCharsetsRoundTrip(t *testing.T) {
+	type testIso struct {
+		F2 *Llvar        `field:"2" length:"10" encode:"ascii"`
+		F3 *Lllvar       `field:"3" length:"10" encode:"cp1252"`
+		F4 *Alphanumeric `field:"4" length:"6" encode:"latin1"`
+		F5 *Llvar        `field:"5" length:"10" encode:"utf8"`
+		F6 *Llvar        `field:"6" length:"10" encode:"binary"`
+	}
+
+	// every byte of the body is kept by Load and Bytes, even the bytes
+	// which are valid UTF-8 or undefined in Windows-1252
+	body := "\xc3\xa9\xe9\x81\x9d\xff"
+	raw := []byte("0100" + "\x7c\x00\x00\x00\x00\x00\x00\x00" +
+		"06" + body + "006" + body + body + "02\xc3\xa9" + "06" + body)
+
+	parser := Parser{}
+	assert.NoError(t, parser.Register("0100", &testIso{}))
+	iso, err := parser.Parse(raw)
+	if !assert.NoError(t, err) {
+		return
+	}
+	loaded := iso.Data.(*testIso)
+	assert.Equal(t, []byte("Ã©é\u0081\u009dÿ"), loaded.F2.Value)
+	assert.Equal(t, []byte("Ã©é\u0081\u009dÿ"), loaded.F3.Value)
+	assert.Equal(t, "Ã©é\u0081\u009dÿ", loaded.F4.Value)
+	assert.Equal(t, []byte("é"), loaded.F5.Value)
+	assert.Equal(t, []byte(body), loaded.F6.Value)
+
+	res, err := iso.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, raw, res)
+}
+
+func TestEbcdic(t *testing.T) {
+	type testIso struct {
+		F2  *Llnumeric    `field:"2" length:"19" encode:"ebcdic,ebcdic"`
+		F3  *Numeric      `field:"3" length:"6" encode:"ebcdic"`
+		F4  *Alphanumeric `field:"4" length:"6" encode:"ebcdic"`
+		F5  *Llvar        `field:"5" length:"99" encode:"ebcdic,ebcdic"`
+		F6  *Lllvar       `field:"6" length:"999" encode:"bcd,ebcdic"`
+		F7  *L8var        `field:"7" length:"999" encode:"ebcdic,ebcdic273"`
+		F8  *Lllnumeric   `field:"8" length:"999" encode:"ebcdic,ascii"`
+		F9  *Alphanumeric `field:"9" length:"3" encode:"ebcdic1141"`
+		F10 *Llvar        `field:"10" length:"99" encode:"ascii,ebcdic1140"`
+	}
+
+	data := &testIso{
+		F2:  NewLlnumeric("4276555555555555"),
+		F3:  NewNumeric("123"),
+		F4:  NewAlphanumeric("ab"),
+		F5:  NewLlvar([]byte("Test text")),
+		F6:  NewLllvar([]byte("lllvar")),
+		F7:  NewL8var([]byte("Straße")),
+		F8:  NewLllnumeric("12345"),
+		F9:  NewAlphanumeric("€"),
+		F10: NewLlvar([]byte("€uro")),
+	}
+
+	iso := Message{Mti: "0800", MtiEncode: EBCDIC, Data: data}
+	res, err := iso.Bytes()
+	assert.NoError(t, err)
+
+	expected := []byte{0xf0, 0xf8, 0xf0, 0xf0, 0x7f, 0xc0, 0, 0, 0, 0, 0, 0}
+	// F2
+	expected = append(expected, 0xf1, 0xf6, 0xf4, 0xf2, 0xf7, 0xf6, 0xf5, 0xf5, 0xf5, 0xf5, 0xf5, 0xf5, 0xf5, 0xf5, 0xf5, 0xf5, 0xf5, 0xf5)
+	// F3, F4
+	expected = append(expected, 0xf0, 0xf0, 0xf0, 0xf1, 0xf2, 0xf3, 0x40, 0x40, 0x40, 0x40, 0x81, 0x82)
+	// F5
+	expected = append(expected, 0xf0, 0xf9, 0xe3, 0x85, 0xa2, 0xa3, 0x40, 0xa3, 0x85, 0xa7, 0xa3)
+	// F6
+	expected = append(expected, 0x00, 0x06, 0x93, 0x93, 0x93, 0xa5, 0x81, 0x99)
+	// F7
+	expected = append(expected, 0xf0, 0xf0, 0xf0, 0xf0, 0xf0, 0xf0, 0xf0, 0xf6, 0xe2, 0xa3, 0x99, 0x81, 0xa1, 0x85)
+	// F8
+	expected = append(expected, 0xf0, 0xf0, 0xf5, 0x31, 0x32, 0x33, 0x34, 0x35)
+	// F9
+	expected = append(expected, 0x40, 0x40, 0x9f)
+	// F10
+	expected = append(expected, 0x30, 0x34, 0x9f, 0xa4, 0x99, 0x96)
+	assert.Equal(t, expected, res)
+
+	iso2 := Message{MtiEncode: EBCDIC, Data: &testIso{}}
+	initStruct(reflect.TypeOf(testIso{}), reflect.ValueOf(iso2.Data))
+	err = iso2.Load(res)
+	assert.NoError(t, err)
+	assert.Equal(t, "0800", iso2.Mti)
+	resultFields := iso2.Data.(*testIso)
+	assert.Equal(t, "4276555555555555", resultFields.F2.Value)
+	assert.Equal(t, "000123", resultFields.F3.Value)
+	assert.Equal(t, "    ab", resultFields.F4.Value)
+	assert.Equal(t, []byte("Test text"), resultFields.F5.Value)
+	assert.Equal(t, []byte("lllvar"), resultFields.F6.Value)
+	assert.Equal(t, []byte("Straße"), resultFields.F7.Value)
+	assert.Equal(t, "12345", resultFields.F8.Value)
+	assert.Equal(t, "  €", resultFields.F9.Value)
+	assert.Equal(t, []byte("€uro"), resultFields.F10.Value)
+
+	parser := Parser{MtiEncode: EBCDIC}
+	err = parser.Register("0800", &testIso{})
+	assert.NoError(t, err)
+	msg, err := parser.Parse(res)
+	assert.NoError(t, err)
+	assert.Equal(t, resultFields, msg.Data)
+
+	// character bitmap is encoded with the code page of MTI
+	type testHex struct {
+		F3 *Numeric `field:"3" length:"6" encode:"ebcdic"`
+	}
+	iso4 := &Message{Mti: "0800", MtiEncode: EBCDIC, ASCIIBitmap: true, Data: &testHex{NewNumeric("000123")}}
+	res, err = iso4.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("\xf0\xf8\xf0\xf0\xf2\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf1\xf2\xf3"), res)
+
+	iso3 := &Message{MtiEncode: EBCDIC, ASCIIBitmap: true, Data: &testHex{NewNumeric("")}}
+	err = iso3.Load(res)
+	assert.NoError(t, err)
+	assert.Equal(t, iso4, iso3)
+}
+
+type testCurrency string
+
+func (c testCurrency) MarshalText() ([]byte, error) {
+	switch c {
+	case "USD":
+		return []byte("840"), nil
+	case "EUR":
+		return []byte("978"), nil
+	}
+	return nil, fmt.Errorf("unknown currency %s", string(c))
+}
+
+func (c *testCurrency) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "840":
+		*c = "USD"
+	case "978":
+		*c = "EUR"
+	default:
+		return fmt.Errorf("unknown currency code %s", text)
+	}
+	return nil
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	type testIso struct {
+		PAN      string       `field:"2" type:"llnumeric" length:"19"`
+		Code     *int64       `field:"3" type:"numeric" length:"6"`
+		Amount   int64        `field:"4" type:"numeric,omitempty" length:"12"`
+		Time     time.Time    `field:"7" type:"numeric,omitempty" format:"MMDDhhmmss"`
+		STAN     uint32       `field:"11" type:"numeric,omitempty" length:"6" encode:"bcd"`
+		Terminal string       `field:"41" type:"alphanumeric,omitempty" length:"8"`
+		Currency testCurrency `field:"49" type:"numeric,omitempty" length:"3"`
+		PinBlock []byte       `field:"52" type:"binary,omitempty" length:"8"`
+		Data     []byte       `field:"55" type:"lllvar,omitempty" length:"999" encode:"binary"`
+		Extra    *Llvar       `field:"62" length:"99"`
+	}
+
+	code := int64(0)
+	data := &testIso{
+		PAN:      "4276555555555555",
+		Code:     &code,
+		Amount:   77700,
+		Time:     time.Date(0, time.July, 1, 11, 18, 44, 0, time.UTC),
+		STAN:     123,
+		Terminal: "T1",
+		Currency: "USD",
+		PinBlock: []byte{1, 2, 3, 4, 5, 6, 7, 8},
+		Data:     []byte{0x9f, 0x26, 0x01, 0x00},
+		Extra:    NewLlvar([]byte("extra")),
+	}
+
+	res, err := Marshal("0100", data)
+	assert.NoError(t, err)
+
+	expected := "0100\x72\x20\x00\x00\x00\x80\x92\x04" +
+		"164276555555555555" + "000000" + "000000077700" + "0701111844" + "\x00\x01\x23" +
+		"      T1" + "840" + "\x01\x02\x03\x04\x05\x06\x07\x08" + "004\x9f\x26\x01\x00" + "05extra"
+	assert.Equal(t, expected, string(res))
+
+	result := &testIso{}
+	err = Unmarshal(res, result)
+	assert.NoError(t, err)
+	assert.Equal(t, data.PAN, result.PAN)
+	assert.Equal(t, &code, result.Code)
+	assert.Equal(t, data.Amount, result.Amount)
+	assert.True(t, data.Time.Equal(result.Time))
+	assert.Equal(t, data.STAN, result.STAN)
+	// padding of Alphanumeric is trimmed
+	assert.Equal(t, data.Terminal, result.Terminal)
+	assert.Equal(t, data.Currency, result.Currency)
+	assert.Equal(t, data.PinBlock, result.PinBlock)
+	assert.Equal(t, data.Data, result.Data)
+	assert.Equal(t, data.Extra, result.Extra)
+
+	// zero values with omitempty and nil pointers are omitted
+	res, err = Marshal("0100", &testIso{PAN: "4276555555555555"})
+	assert.NoError(t, err)
+	assert.Equal(t, "0100\x40\x00\x00\x00\x00\x00\x00\x00164276555555555555", string(res))
+
+	result = &testIso{}
+	err = Unmarshal(res, result)
+	assert.NoError(t, err)
+	assert.Nil(t, result.Code)
+
+	// parser supports native templates
+	parser := Parser{}
+	err = parser.Register("0100", &testIso{})
+	assert.NoError(t, err)
+	msg, err := parser.Parse(res)
+	assert.NoError(t, err)
+	assert.Equal(t, "4276555555555555", msg.Data.(*testIso).PAN)
+	assert.Nil(t, msg.Data.(*testIso).Code)
+}
+
+func TestMarshalUnmarshalErrors(t *testing.T) {
+	type test1 struct {
+		F2 float64 `field:"2" type:"numeric" length:"6"`
+	}
+	_, err := Marshal("0100", &test1{1})
+	assert.EqualError(t, err, "invalid template: field F2: unsupported native type: float64")
+
+	type test2 struct {
+		F2 string `field:"2" type:"test" length:"6"`
+	}
+	_, err = Marshal("0100", &test2{"1"})
+	assert.EqualError(t, err, "invalid template: field F2: unknown field type: test")
+
+	type test3 struct {
+		F7 time.Time `field:"7" type:"numeric" length:"10"`
+	}
+	_, err = Marshal("0100", &test3{time.Now()})
+	assert.EqualError(t, err, "invalid template: field F7: format is required for time.Time")
+
+	type test4 struct {
+		F49 testCurrency `field:"49" type:"numeric" length:"3"`
+	}
+	_, err = Marshal("0100", &test4{"ABC"})
+	assert.EqualError(t, err, "field 49: unknown currency ABC")
+
+	err = Unmarshal([]byte("0100\x00\x00\x00\x00\x00\x40\x00\x00999"), &test4{})
+	assert.EqualError(t, err, "field 42: not defined in template")
+
+	err = Unmarshal([]byte("0100\x00\x00\x00\x00\x00\x00\x80\x00999"), &test4{})
+	assert.EqualError(t, err, "field 49: unknown currency code 999")
+
+	type test5 struct {
+		F4 int64 `field:"4" type:"alphanumeric" length:"3"`
+	}
+	err = Unmarshal([]byte("0100\x10\x00\x00\x00\x00\x00\x00\x00abc"), &test5{})
+	assert.True(t, strings.HasPrefix(err.Error(), "field 4: bad raw data: strconv.ParseInt"))
+	assert.True(t, errors.Is(err, ErrBadRaw))
+
+	err = Unmarshal([]byte("0100"), test5{})
+	assert.EqualError(t, err, "invalid template: template must be a pointer to struct")
+	assert.True(t, errors.Is(err, ErrInvalidTemplate))
+
+	type test6 struct {
+		F4 int64 `field:"4" type:"numeric" length:"12"`
+	}
+	_, err = Marshal("0100", &test6{-5})
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+	assert.EqualError(t, err, "field 4: invalid value: negative value -5")
+}
+
+func TestMarshalZeroValues(t *testing.T) {
+	type testIso struct {
+		Amount int64  `field:"4" type:"numeric" length:"12"`
+		Fee    int64  `field:"28" type:"numeric,omitempty" length:"8"`
+		Text   string `field:"48" type:"llvar"`
+	}
+
+	// zero values are sent unless omitempty is set
+	res, err := Marshal("0100", &testIso{})
+	assert.NoError(t, err)
+	assert.Equal(t, "0100\x10\x00\x00\x00\x00\x01\x00\x00"+"000000000000"+"00", string(res))
+
+	result := &testIso{Amount: 1, Text: "text"}
+	assert.NoError(t, Unmarshal(res, result))
+	assert.Equal(t, &testIso{}, result)
+
+	res, err = Marshal("0100", &testIso{Amount: 100, Fee: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, "0100\x10\x00\x00\x10\x00\x01\x00\x00"+"000000000100"+"00000005"+"00", string(res))
+}
+
+func TestTemplateCache(t *testing.T) {
+	tp := reflect.TypeOf(TestISO{})
+	tpl, err := templateOf(tp)
+	assert.NoError(t, err)
+	cached, _ := templateOf(tp)
+	assert.Same(t, tpl, cached)
+	assert.Equal(t, 22, len(tpl.fields))
+	assert.Equal(t, 2, tpl.fields[0].Index)
+	assert.Equal(t, 120, tpl.fields[len(tpl.fields)-1].Index)
+	assert.Equal(t, rBCD, tpl.byIndex[19].Encode)
+
+	// template is compiled on registration
+	type test1 struct {
+		F2 *Llnumeric `field:"2" length:"abc"`
+	}
+	parser := Parser{}
+	err = parser.Register("0100", &test1{})
+	assert.EqualError(t, err, "invalid template: field F2: value of length must be numeric")
+}
+
+func benchmarkData() *TestISO {
+	return &TestISO{
+		F2:   NewLlnumeric("4276555555555555"),
+		F3:   NewNumeric("000000"),
+		F4:   NewNumeric("000000077700"),
+		F7:   NewNumeric("0701111844"),
+		F11:  NewNumeric("000123"),
+		F12:  NewNumeric("131844"),
+		F13:  NewNumeric("0701"),
+		F14:  NewNumeric("1902"),
+		F19:  NewNumeric("643"),
+		F22:  NewNumeric("901"),
+		F25:  NewNumeric("02"),
+		F32:  NewLlnumeric("123456"),
+		F35:  NewLlnumeric("4276555555555555=12345678901234567890"),
+		F37:  NewAlphanumeric("987654321001"),
+		F41:  NewAlphanumeric("00000321"),
+		F42:  NewAlphanumeric("120000000000034"),
+		F43:  NewAlphanumeric("Test text"),
+		F49:  NewNumeric("643"),
+		F52:  NewBinary([]byte{1, 2, 3, 4, 5, 6, 7, 8}),
+		F53:  NewNumeric("1234000000000000"),
+		F120: NewLllnumeric("Another test text"),
+	}
+}
+
+func BenchmarkPack(b *testing.B) {
+	iso := NewMessage("0100", benchmarkData())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := iso.Bytes(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnpack(b *testing.B) {
+	raw, err := NewMessage("0100", benchmarkData()).Bytes()
+	if err != nil {
+		b.Fatal(err)
+	}
+	iso := NewMessage("", newDataIso())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		iso.Mti = ""
+		if err := iso.Load(raw); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	raw, err := NewMessage("0100", benchmarkData()).Bytes()
+	if err != nil {
+		b.Fatal(err)
+	}
+	parser := Parser{}
+	if err := parser.Register("0100", &TestISO{}); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.Parse(raw); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestBinaryVar(t *testing.T) {
+	type testIso struct {
+		F48 *Lllbin `field:"48" length:"999" encode:"bcd,ascii"`
+		F55 *Llbin  `field:"55" length:"255" encode:"bin,ascii"`
+		F62 *Lllbin `field:"62" length:"999" encode:"bin,ascii"`
+		F63 *Llbin  `field:"63" length:"99"`
+	}
+
+	// valid UTF-8 is sent as is
+	icc := []byte{0x9f, 0x26, 0x02, 0xc3, 0x9f}
+	data := &testIso{
+		F48: NewLllbin([]byte("ab")),
+		F55: NewLlbin(icc),
+		F62: NewLllbin([]byte("Straße")),
+		F63: NewLlbin([]byte{0xe2, 0x82, 0xac}),
+	}
+	res, err := Marshal("0100", data)
+	assert.NoError(t, err)
+	expected := "0100\x00\x00\x00\x00\x00\x01\x02\x06" +
+		"\x00\x02ab" + "\x05\x9f\x26\x02\xc3\x9f" + "\x00\x07Straße" + "03\xe2\x82\xac"
+	assert.Equal(t, expected, string(res))
+
+	parser := Parser{}
+	assert.NoError(t, parser.Register("0100", &testIso{}))
+	msg, err := parser.Parse(res)
+	assert.NoError(t, err)
+	assert.Equal(t, data, msg.Data)
+
+	type testNative struct {
+		F55 []byte `field:"55" type:"llbin" length:"255" encode:"bin,ascii"`
+	}
+	result := &testNative{}
+	assert.NoError(t, Unmarshal([]byte("0100\x00\x00\x00\x00\x00\x00\x02\x00\x02\xc3\x9f"), result))
+	assert.Equal(t, []byte{0xc3, 0x9f}, result.F55)
+
+	// length head of 1 byte can't exceed 255
+	_, err = NewLlbin(make([]byte, 256)).Bytes(ASCII, BIN, -1)
+	assert.Equal(t, ErrInvalidLengthHead, err)
+	_, err = NewLlbin(make([]byte, 100)).Bytes(ASCII, ASCII, -1)
+	assert.Equal(t, ErrInvalidLengthHead, err)
+	_, err = NewLlbin([]byte("abc")).Bytes(BCD, BIN, -1)
+	assert.Equal(t, ErrInvalidEncoder, err)
+
+	_, err = NewLllbin(nil).Load([]byte{0x00}, ASCII, BIN, -1)
+	assert.Equal(t, ErrBadRaw, err)
+	_, err = NewLllbin(nil).Load([]byte{0x00, 0x03, 0x01}, ASCII, BIN, -1)
+	assert.Equal(t, ErrBadRaw, err)
+	_, err = NewLlbin(nil).Load([]byte{0x05, 1, 2, 3, 4, 5}, ASCII, BIN, 4)
+	assert.True(t, errors.Is(err, ErrValueTooLong))
+
+	// maximum length depends on the length encoder
+	type testLong struct {
+		F55 *Llbin  `field:"55" length:"255"`
+		F62 *Lllbin `field:"62" length:"1000" encode:"bcd,ascii"`
+		F63 *Lllbin `field:"63" length:"65535" encode:"bin,ascii"`
+	}
+	err = parser.Register("0100", &testLong{})
+	var errs TemplateErrors
+	assert.True(t, errors.As(err, &errs))
+	assert.Equal(t, []string{
+		"invalid template: field F55: length 255 exceeds maximum 99 of Llbin",
+		"invalid template: field F62: length 1000 exceeds maximum 999 of Lllbin",
+	}, templateErrorStrings(errs))
+
+	// bin length head is only supported by binary var fields
+	type testBad struct {
+		F2 *Llvar `field:"2" length:"99" encode:"bin,ascii"`
+	}
+	err = parser.Register("0200", &testBad{})
+	assert.EqualError(t, err, "invalid template: field F2: length encoding bin isn't supported by Llvar")
+}
+
+// lengthHeader is a custom header with ASCII message length and a
+// terminal ID
+type lengthHeader struct {
+	Length   int
+	Terminal string
+}
+
+func (h *lengthHeader) Bytes() ([]byte, error) {
+	return []byte(fmt.Sprintf("%04d%-8s", h.Length, h.Terminal)), nil
+}
+
+func (h *lengthHeader) Load(raw []byte) (int, error) {
+	if len(raw) < 12 {
+		return 0, ErrBadRaw
+	}
+	l, err := strconv.Atoi(string(raw[:4]))
+	if err != nil {
+		return 0, ErrBadRaw
+	}
+	h.Length = l
+	h.Terminal = strings.TrimSpace(string(raw[4:12]))
+	return 12, nil
+}
+
+func TestHeaders(t *testing.T) {
+	type testIso struct {
+		F3  *Numeric `field:"3" length:"6"`
+		F11 *Numeric `field:"11" length:"6"`
+	}
+	body := "0800" + "\x20\x20\x00\x00\x00\x00\x00\x00" + "990000" + "000123"
+
+	// fixed opaque header
+	iso := NewMessage("0800", &testIso{NewNumeric("990000"), NewNumeric("123")})
+	iso.Header = &FixedHeader{Length: 12, Value: []byte("ISO026000075")}
+	b, err := iso.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "ISO026000075"+body, string(b))
+
+	loaded := NewMessage("", &testIso{NewNumeric(""), NewNumeric("")})
+	loaded.Header = NewFixedHeader(12)
+	assert.NoError(t, loaded.Load(b))
+	assert.Equal(t, "0800", loaded.Mti)
+	assert.Equal(t, []byte("ISO026000075"), loaded.Header.(*FixedHeader).Value)
+	assert.Equal(t, "000123", loaded.Data.(*testIso).F11.Value)
+
+	iso.Header = &FixedHeader{Length: 12, Value: []byte("ISO")}
+	_, err = iso.Bytes()
+	assert.True(t, errors.Is(err, ErrInvalidHeader))
+
+	// TPDU of POS terminals, swapped for the response
+	parser := Parser{Header: func() Header { return &TPDU{} }}
+	assert.NoError(t, parser.Register("0800", &testIso{}))
+	assert.NoError(t, parser.Register("0810", &testIso{}))
+	parsed, err := parser.Parse(append([]byte("\x60\x00\x03\x00\x00"), body...))
+	assert.NoError(t, err)
+	assert.Equal(t, "0800", parsed.Mti)
+	tpdu := parsed.Header.(*TPDU)
+	assert.Equal(t, &TPDU{0x60, [2]byte{0x00, 0x03}, [2]byte{0x00, 0x00}}, tpdu)
+
+	resp := NewMessage("0810", parsed.Data)
+	resp.Header = tpdu.Response()
+	b, err = resp.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "\x60\x00\x00\x00\x03"+"0810"+body[4:], string(b))
+	assert.Equal(t, [2]byte{0x00, 0x03}, tpdu.Destination)
+
+	// offsets of field errors count the header
+	_, err = parser.Parse(append([]byte("\x60\x00\x03\x00\x00"), body[:20]...))
+	var fe *FieldError
+	if assert.True(t, errors.As(err, &fe)) {
+		assert.Equal(t, 11, fe.Index)
+		assert.Equal(t, 23, fe.Offset)
+	}
+	_, err = parser.Parse([]byte("\x60\x00"))
+	assert.True(t, errors.Is(err, ErrBadRaw))
+
+	// custom header struct
+	iso = NewMessage("0800", &testIso{NewNumeric("990000"), NewNumeric("123")})
+	iso.Header = &lengthHeader{len(body), "T1"}
+	b, err = iso.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "0024T1      "+body, string(b))
+
+	parser = Parser{Header: func() Header { return &lengthHeader{} }}
+	assert.NoError(t, parser.Register("0800", &testIso{}))
+	parsed, err = parser.Parse(b)
+	assert.NoError(t, err)
+	assert.Equal(t, &lengthHeader{24, "T1"}, parsed.Header)
+}
+
+func TestParserFallback(t *testing.T) {
+	type testIso struct {
+		F3 *Numeric `field:"3" length:"6"`
+	}
+	raw, err := ioutil.ReadFile(filepath.Join("test", "testdata", "network_management_message.dat"))
+	assert.NoError(t, err)
+
+	parser := Parser{}
+	assert.NoError(t, parser.Register("0100", &testIso{}))
+	_, err = parser.Parse(raw)
+	assert.True(t, errors.Is(err, ErrUnknownMti))
+
+	// unregistered MTI is decoded with the specification
+	parser.Fallback = &utils.ISO8583DataElementsVer1987
+	msg, err := parser.Parse(raw)
+	assert.NoError(t, err)
+	assert.Equal(t, "0800", msg.Mti)
+	generic, ok := msg.Data.(lib.Iso8583Message)
+	if assert.True(t, ok) {
+		assert.Equal(t, "0800", generic.GetMti().String())
+		assert.Equal(t, "0420090613", generic.GetElements()[7].String())
+	}
+	b, err := msg.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, raw, b)
+
+	// registered MTI is decoded with the template
+	msg, err = parser.Parse([]byte("0100" + "\x20\x00\x00\x00\x00\x00\x00\x00" + "000000"))
+	assert.NoError(t, err)
+	assert.Equal(t, "000000", msg.Data.(*testIso).F3.Value)
+
+	// header precedes the generic message
+	parser.Header = func() Header { return NewFixedHeader(2) }
+	msg, err = parser.Parse(append([]byte("H1"), raw...))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("H1"), msg.Header.(*FixedHeader).Value)
+	b, err = msg.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, append([]byte("H1"), raw...), b)
+
+	_, err = parser.Parse(append([]byte("H1"), raw[:20]...))
+	assert.Error(t, err)
+}
+
+func TestParserPatterns(t *testing.T) {
+	type authIso struct {
+		F3 *Numeric `field:"3" length:"6"`
+	}
+	type responseIso struct {
+		F3  *Numeric      `field:"3" length:"6"`
+		F39 *Alphanumeric `field:"39" length:"2"`
+	}
+	type networkIso struct {
+		F70 *Numeric `field:"70" length:"3"`
+	}
+	parser := Parser{}
+	assert.NoError(t, parser.Register("x1x0", &authIso{}))
+	assert.NoError(t, parser.Register("01x0", &authIso{}))
+	assert.NoError(t, parser.Register("0110", &responseIso{}))
+	assert.NoError(t, parser.Register("X8XX", &networkIso{}))
+
+	request := "\x20\x00\x00\x00\x00\x00\x00\x00" + "000000"
+	response := "\x20\x00\x00\x00\x02\x00\x00\x00" + "000000" + "00"
+	tests := []struct {
+		raw      string
+		expected interface{}
+	}{
+		{"0100" + request, &authIso{}},
+		{"0110" + response, &responseIso{}},
+		{"1100" + request, &authIso{}},
+		{"2120" + request, &authIso{}},
+	}
+	for _, tt := range tests {
+		msg, err := parser.Parse([]byte(tt.raw))
+		if assert.NoError(t, err, tt.raw) {
+			assert.IsType(t, tt.expected, msg.Data, tt.raw)
+		}
+	}
+	_, err := parser.Parse([]byte("0200" + request))
+	assert.True(t, errors.Is(err, ErrUnknownMti))
+
+	// factory chooses templates by MTI components
+	var chosen []MTI
+	parser.Factory = func(mti MTI) interface{} {
+		chosen = append(chosen, mti)
+		if mti.Class == 2 && mti.Function == 1 {
+			return &responseIso{}
+		}
+		if mti.Class == 2 {
+			return &authIso{}
+		}
+		return nil
+	}
+	msg, err := parser.Parse([]byte("0200" + request))
+	assert.NoError(t, err)
+	assert.Equal(t, "000000", msg.Data.(*authIso).F3.Value)
+	msg, err = parser.Parse([]byte("2210" + response))
+	assert.NoError(t, err)
+	assert.Equal(t, "00", msg.Data.(*responseIso).F39.Value)
+	_, err = parser.Parse([]byte("0300" + request))
+	assert.True(t, errors.Is(err, ErrUnknownMti))
+	assert.Equal(t, []MTI{{Version1987, 2, 0, 0}, {Version2003, 2, 1, 0}, {Version1987, 3, 0, 0}}, chosen)
+
+	parser.Factory = func(mti MTI) interface{} { return authIso{} }
+	_, err = parser.Parse([]byte("0300" + request))
+	assert.True(t, errors.Is(err, ErrInvalidTemplate))
+
+	for _, mti := range []string{"01y0", "010", "x10000"} {
+		err = parser.Register(mti, &authIso{})
+		assert.True(t, errors.Is(err, ErrInvalidMti), mti)
+	}
+
+	m, err := ParseMTI("1430")
+	assert.NoError(t, err)
+	assert.Equal(t, MTI{Version1993, 4, 3, 0}, m)
+	assert.Equal(t, "1430", m.String())
+	_, err = ParseMTI("14x0")
+	assert.True(t, errors.Is(err, ErrInvalidMti))
+}
+
+// hookIso fills the STAN before packing and checks the response code
+// after unpacking
+type hookIso struct {
+	F3  *Numeric      `field:"3" length:"6"`
+	F11 *Numeric      `field:"11" length:"6"`
+	F39 *Alphanumeric `field:"39" length:"2"`
+
+	stan   int
+	unpack string
+}
+
+func (h *hookIso) BeforePack(m *Message) error {
+	if h.F3 == nil {
+		return errors.New("processing code is missing")
+	}
+	h.stan++
+	h.F11 = NewNumeric(fmt.Sprint(h.stan))
+	return nil
+}
+
+func (h *hookIso) AfterUnpack(m *Message) error {
+	h.unpack = m.Mti
+	if m.Mti == "0110" && h.F39.Value == "" {
+		return errors.New("response code is missing")
+	}
+	return nil
+}
+
+func (h *hookIso) Validate() error {
+	if h.F3 != nil && h.F3.Value == "999999" {
+		return errors.New("invalid processing code")
+	}
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	data := &hookIso{F3: NewNumeric("000000")}
+	iso := NewMessage("0100", data)
+	b, err := iso.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "0100"+"\x20\x20\x00\x00\x00\x00\x00\x00"+"000000"+"000001", string(b))
+	assert.Equal(t, 1, data.stan)
+
+	parser := Parser{}
+	assert.NoError(t, parser.Register("01x0", &hookIso{}))
+	msg, err := parser.Parse(b)
+	assert.NoError(t, err)
+	assert.Equal(t, "0100", msg.Data.(*hookIso).unpack)
+	assert.Equal(t, "000001", msg.Data.(*hookIso).F11.Value)
+
+	// errors are wrapped with MTI and hook
+	var he *HookError
+	_, err = parser.Parse([]byte("0110" + "\x20\x00\x00\x00\x00\x00\x00\x00" + "000000"))
+	if assert.True(t, errors.As(err, &he)) {
+		assert.Equal(t, "0110", he.Mti)
+		assert.Equal(t, "AfterUnpack", he.Hook)
+	}
+	assert.EqualError(t, err, "MTI 0110: AfterUnpack: response code is missing")
+
+	_, err = parser.Parse([]byte("0100" + "\x20\x00\x00\x00\x00\x00\x00\x00" + "999999"))
+	assert.EqualError(t, err, "MTI 0100: Validate: invalid processing code")
+
+	_, err = NewMessage("0200", &hookIso{}).Bytes()
+	assert.EqualError(t, err, "MTI 0200: BeforePack: processing code is missing")
+
+	data.F3.Value = "999999"
+	_, err = iso.Bytes()
+	if assert.True(t, errors.As(err, &he)) {
+		assert.Equal(t, "Validate", he.Hook)
+	}
+}
+
+func TestStream(t *testing.T) {
+	type testIso struct {
+		F3 *Numeric `field:"3" length:"6"`
+	}
+	raw := "0100" + "\x20\x00\x00\x00\x00\x00\x00\x00" + "000000"
+	parser := &Parser{}
+	assert.NoError(t, parser.Register("0100", &testIso{}))
+
+	tests := []struct {
+		framing Framing
+		prefix  string
+	}{
+		{FramingBinary2, "\x00\x12"},
+		{FramingASCII4, "0018"},
+		{FramingBCD2, "\x00\x18"},
+		{FramingRDW, "\x00\x16\x00\x00"},
+	}
+	for _, tt := range tests {
+		buf := new(bytes.Buffer)
+		enc := NewEncoder(buf, StreamOptions{Framing: tt.framing})
+		assert.NoError(t, enc.Encode(NewMessage("0100", &testIso{NewNumeric("1")})))
+		assert.NoError(t, enc.WriteRaw([]byte(raw)))
+		assert.Equal(t, tt.prefix+"0100"+"\x20\x00\x00\x00\x00\x00\x00\x00"+"000001"+tt.prefix+raw, buf.String())
+
+		dec := NewDecoder(buf, StreamOptions{Framing: tt.framing, Parser: parser})
+		msg, err := dec.Decode()
+		assert.NoError(t, err)
+		assert.Equal(t, "000001", msg.Data.(*testIso).F3.Value)
+		msg, err = dec.Decode()
+		assert.NoError(t, err)
+		assert.Equal(t, "000000", msg.Data.(*testIso).F3.Value)
+		_, err = dec.Decode()
+		assert.Equal(t, io.EOF, err)
+	}
+
+	// default binary framing with specification
+	network, err := ioutil.ReadFile(filepath.Join("test", "testdata", "network_management_message.dat"))
+	assert.NoError(t, err)
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewEncoder(buf, StreamOptions{}).WriteRaw(network))
+	msg, err := NewDecoder(buf, StreamOptions{Specification: &utils.ISO8583DataElementsVer1987}).Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, "0800", msg.Mti)
+	assert.Implements(t, (*lib.Iso8583Message)(nil), msg.Data)
+
+	// maximum size
+	err = NewEncoder(new(bytes.Buffer), StreamOptions{MaxSize: 10}).WriteRaw([]byte(raw))
+	assert.True(t, errors.Is(err, ErrMessageTooLarge))
+	_, err = NewDecoder(strings.NewReader("\x00\x12"+raw), StreamOptions{MaxSize: 10, Parser: parser}).Decode()
+	assert.True(t, errors.Is(err, ErrMessageTooLarge))
+	_, err = NewDecoder(strings.NewReader("9999"), StreamOptions{Framing: FramingASCII4, MaxSize: 20000}).ReadRaw()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	// broken streams
+	_, err = NewDecoder(strings.NewReader("\x00\x12"+raw[:5]), StreamOptions{Parser: parser}).Decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = NewDecoder(strings.NewReader("\x00"), StreamOptions{Parser: parser}).Decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = NewDecoder(strings.NewReader("00a8"+raw), StreamOptions{Framing: FramingASCII4, Parser: parser}).Decode()
+	assert.True(t, errors.Is(err, ErrInvalidFraming))
+	_, err = NewDecoder(strings.NewReader("\x00\x16\x01\x00"+raw), StreamOptions{Framing: FramingRDW, Parser: parser}).Decode()
+	assert.True(t, errors.Is(err, ErrInvalidFraming))
+	_, err = NewDecoder(strings.NewReader("\x00\x12"+raw), StreamOptions{}).Decode()
+	assert.Equal(t, ErrNoParser, err)
+}
+
+func TestLibBridge(t *testing.T) {
+	type testIso struct {
+		PAN      string        `field:"2" type:"llnumeric" length:"19"`
+		Code     *Numeric      `field:"3" length:"6"`
+		Amount   int64         `field:"4" type:"numeric,omitempty" length:"12"`
+		STAN     *int          `field:"11" type:"numeric" length:"6"`
+		Terminal *Alphanumeric `field:"41" length:"8"`
+		Data     *Lllvar       `field:"48" length:"999"`
+		PIN      *Binary       `field:"52" length:"8"`
+		Network  *Numeric      `field:"70" length:"3"`
+	}
+	stan := 123
+	data := &testIso{
+		PAN:      "4111111111111111",
+		Code:     NewNumeric("000000"),
+		Amount:   100,
+		STAN:     &stan,
+		Terminal: NewAlphanumeric("TERM0001"),
+		Data:     NewLllvar([]byte("ADDITIONAL DATA")),
+		Network:  NewNumeric("301"),
+	}
+
+	spec, err := TemplateSpecification(data)
+	assert.NoError(t, err)
+	assert.Equal(t, utils.Attributes{
+		1:  {Describe: "b 64", Description: "Second Bitmap"},
+		2:  {Describe: "n..19", Description: "PAN"},
+		3:  {Describe: "n 6", Description: "Code"},
+		4:  {Describe: "n 12", Description: "Amount"},
+		11: {Describe: "n 6", Description: "STAN"},
+		41: {Describe: "ans 8", Description: "Terminal"},
+		48: {Describe: "ans...999", Description: "Data"},
+		52: {Describe: "b 64", Description: "PIN"},
+		70: {Describe: "n 3", Description: "Network"},
+	}, *spec.Elements)
+
+	// same bytes as the message with ASCII bitmap
+	iso := NewMessage("0200", data)
+	iso.ASCIIBitmap = true
+	expected, err := iso.Bytes()
+	assert.NoError(t, err)
+	generic, err := iso.ToLib(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, generic.Validate())
+	assert.Equal(t, "0200", generic.GetMti().String())
+	assert.Equal(t, "123", generic.GetElements()[11].String())
+	b, err := generic.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(b))
+
+	// binary fields are bits of pkg/lib elements
+	data.PIN = NewBinary([]byte{0x12, 0x34, 0, 0, 0, 0, 0, 0xFF})
+	generic, err = iso.ToLib(spec)
+	assert.NoError(t, err)
+	assert.Equal(t, "0001001000110100"+strings.Repeat("0", 40)+"11111111", generic.GetElements()[52].String())
+
+	loaded := NewMessage("", &testIso{})
+	assert.NoError(t, loaded.FromLib(generic))
+	assert.Equal(t, "0200", loaded.Mti)
+	assert.Equal(t, data, loaded.Data)
+
+	// message types of the parser have mandatory native fields
+	parser := Parser{}
+	assert.NoError(t, parser.Register("02x0", &testIso{}))
+	spec, err = parser.Specification("0210")
+	assert.NoError(t, err)
+	assert.Equal(t, utils.MessageTypes{
+		"0210": {
+			MandatoryHexMask: "40000000000000000000000000000000",
+			OptionalHexMask:  "30200000008110000400000000000000",
+		},
+	}, *spec.MessageTypes)
+	_, err = parser.Specification("0100")
+	assert.True(t, errors.Is(err, ErrUnknownMti))
+
+	// fields with other encodings override them
+	type mixedIso struct {
+		F3  *Numeric      `field:"3" length:"6" encode:"bcd"`
+		F4  *Numeric      `field:"4" length:"12"`
+		F43 *Alphanumeric `field:"43" length:"6" encode:"ebcdic"`
+	}
+	mixed := &Message{Mti: "0200", ASCIIBitmap: true, Data: &mixedIso{NewNumeric("000000"), NewNumeric("000000000100"), NewAlphanumeric("MOOVIO")}}
+	spec, err = TemplateSpecification(mixed.Data)
+	assert.NoError(t, err)
+	assert.Equal(t, utils.EncodingBcd, spec.Encoding.NumberEnc)
+	assert.Equal(t, utils.EncodingEbcdic, spec.Encoding.CharacterEnc)
+	assert.Equal(t, utils.EncodingChar, (*spec.Elements)[4].Encoding)
+	assert.Empty(t, (*spec.Elements)[43].Encoding)
+	expected, err = mixed.Bytes()
+	assert.NoError(t, err)
+	generic, err = mixed.ToLib(spec)
+	assert.NoError(t, err)
+	b, err = generic.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, b)
+
+	type unsupported struct {
+		F2 *Llbin `field:"2" length:"20"`
+	}
+	_, err = TemplateSpecification(&unsupported{})
+	assert.EqualError(t, err, "invalid template: field F2: Llbin isn't supported by specifications")
+
+	type otherIso struct {
+		F3 *Numeric `field:"3" length:"6"`
+	}
+	err = NewMessage("", &otherIso{}).FromLib(generic)
+	var fe *FieldError
+	if assert.True(t, errors.As(err, &fe)) {
+		assert.True(t, errors.Is(err, ErrUndefinedField))
+	}
+	assert.Equal(t, errNotPointer, NewMessage("", otherIso{}).FromLib(generic))
 }
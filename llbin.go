@@ -0,0 +1,138 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package iso8583
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Llbin contains raw bytes in non-fixed length field. Length head is 2
+// digits in ascii, bcd or ebcdic, or 1 byte with bin encoder. Value is
+// never converted, encoder must be ascii.
+type Llbin struct {
+	Value []byte
+}
+
+// NewLlbin create new Llbin field
+func NewLlbin(val []byte) *Llbin {
+	return &Llbin{val}
+}
+
+// IsEmpty check Llbin field for empty value
+func (l *Llbin) IsEmpty() bool {
+	return len(l.Value) == 0
+}
+
+// Bytes encode Llbin field to bytes
+func (l *Llbin) Bytes(encoder, lenEncoder, length int) ([]byte, error) {
+	return binBytes("Llbin", l.Value, 2, encoder, lenEncoder, length)
+}
+
+// Load decode Llbin field from bytes
+func (l *Llbin) Load(raw []byte, encoder, lenEncoder, length int) (int, error) {
+	val, read, err := binLoad("Llbin", raw, 2, encoder, lenEncoder, length)
+	if err != nil {
+		return 0, err
+	}
+	l.Value = val
+	return read, nil
+}
+
+// binBytes encodes value of variable length binary field with length head
+// of digits decimal digits, or digits-1 bytes with bin encoder
+func binBytes(typ string, value []byte, digits, encoder, lenEncoder, length int) ([]byte, error) {
+	if encoder != ASCII {
+		return nil, ErrInvalidEncoder
+	}
+	if length != -1 && len(value) > length {
+		return nil, errValueTooLong(typ, length, len(value))
+	}
+
+	var lenVal []byte
+	var err error
+	contentLen := []byte(fmt.Sprintf("%0*d", digits, len(value)))
+	switch lenEncoder {
+	case ASCII:
+		lenVal = contentLen
+	case rBCD, BCD:
+		lenVal, err = rbcd(contentLen)
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		lenVal, err = ebcdicEncode(contentLen, lenEncoder)
+	case BIN:
+		size := digits - 1
+		if len(value) >= 1<<(8*uint(size)) {
+			return nil, ErrInvalidLengthHead
+		}
+		lenVal = make([]byte, size)
+		for i, n := size-1, len(value); i >= 0; i, n = i-1, n>>8 {
+			lenVal[i] = byte(n)
+		}
+		return append(lenVal, value...), nil
+	default:
+		return nil, ErrInvalidLengthEncoder
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(contentLen) > digits {
+		return nil, ErrInvalidLengthHead
+	}
+	return append(lenVal, value...), nil
+}
+
+// binLoad decodes value of variable length binary field, see binBytes
+func binLoad(typ string, raw []byte, digits, encoder, lenEncoder, length int) ([]byte, int, error) {
+	if encoder != ASCII {
+		return nil, 0, ErrInvalidEncoder
+	}
+
+	var read, contentLen int
+	var err error
+	switch lenEncoder {
+	case ASCII:
+		read = digits
+		if len(raw) < read {
+			return nil, 0, ErrBadRaw
+		}
+		contentLen, err = strconv.Atoi(string(raw[:read]))
+	case rBCD, BCD:
+		read = (digits + 1) / 2
+		if len(raw) < read {
+			return nil, 0, ErrBadRaw
+		}
+		contentLen, err = strconv.Atoi(string(bcdr2Ascii(raw[:read], digits)))
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		read = digits
+		if len(raw) < read {
+			return nil, 0, ErrBadRaw
+		}
+		var lenVal []byte
+		lenVal, err = ebcdicDecode(raw[:read], lenEncoder)
+		if err == nil {
+			contentLen, err = strconv.Atoi(string(lenVal))
+		}
+	case BIN:
+		read = digits - 1
+		if len(raw) < read {
+			return nil, 0, ErrBadRaw
+		}
+		for _, b := range raw[:read] {
+			contentLen = contentLen<<8 | int(b)
+		}
+	default:
+		return nil, 0, ErrInvalidLengthEncoder
+	}
+	if err != nil {
+		return nil, 0, errParseLength(raw[:read])
+	}
+	if length != -1 && contentLen > length {
+		return nil, 0, errValueTooLong(typ, length, contentLen)
+	}
+	if len(raw) < read+contentLen {
+		return nil, 0, ErrBadRaw
+	}
+	return raw[read : read+contentLen], read + contentLen, nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package iso8583
+
+// Lllbin contains raw bytes in non-fixed length field. Length head is 3
+// digits in ascii, bcd or ebcdic, or 2 bytes big-endian with bin encoder.
+// Value is never converted, encoder must be ascii.
+type Lllbin struct {
+	Value []byte
+}
+
+// NewLllbin create new Lllbin field
+func NewLllbin(val []byte) *Lllbin {
+	return &Lllbin{val}
+}
+
+// IsEmpty check Lllbin field for empty value
+func (l *Lllbin) IsEmpty() bool {
+	return len(l.Value) == 0
+}
+
+// Bytes encode Lllbin field to bytes
+func (l *Lllbin) Bytes(encoder, lenEncoder, length int) ([]byte, error) {
+	return binBytes("Lllbin", l.Value, 3, encoder, lenEncoder, length)
+}
+
+// Load decode Lllbin field from bytes
+func (l *Lllbin) Load(raw []byte, encoder, lenEncoder, length int) (int, error) {
+	val, read, err := binLoad("Lllbin", raw, 3, encoder, lenEncoder, length)
+	if err != nil {
+		return 0, err
+	}
+	l.Value = val
+	return read, nil
+}
@@ -5,7 +5,6 @@
 package iso8583
 
 import (
-	"errors"
 	"fmt"
 	"strconv"
 )
@@ -27,15 +26,21 @@ func (l *Lllvar) IsEmpty() bool {
 
 // Bytes encode Lllvar field to bytes
 func (l *Lllvar) Bytes(encoder, lenEncoder, length int) ([]byte, error) {
-	val, err := UTF8ToWindows1252(l.Value)
+	var val []byte
+	var err error
+	switch encoder {
+	case ASCII, CP1252, LATIN1, UTF8, BINARY:
+		val, err = charsetEncode(l.Value, encoder)
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		val, err = ebcdicEncode(l.Value, encoder)
+	default:
+		return nil, ErrInvalidEncoder
+	}
 	if err != nil {
 		return nil, err
 	}
 	if length != -1 && len(val) > length {
-		return nil, fmt.Errorf(ErrValueTooLong, "Lllvar", length, len(val))
-	}
-	if encoder != ASCII {
-		return nil, errors.New(ErrInvalidEncoder)
+		return nil, errValueTooLong("Lllvar", length, len(val))
 	}
 
 	lenStr := fmt.Sprintf("%03d", len(val))
@@ -45,56 +50,93 @@ func (l *Lllvar) Bytes(encoder, lenEncoder, length int) ([]byte, error) {
 	case ASCII:
 		lenVal = contentLen
 		if len(lenVal) > 3 {
-			return nil, errors.New(ErrInvalidLengthHead)
+			return nil, ErrInvalidLengthHead
 		}
 	case rBCD:
 		fallthrough
 	case BCD:
-		lenVal = rbcd(contentLen)
+		lenVal, err = rbcd(contentLen)
+		if err != nil {
+			return nil, err
+		}
 		if len(lenVal) > 2 || len(contentLen) > 3 {
-			return nil, errors.New(ErrInvalidLengthHead)
+			return nil, ErrInvalidLengthHead
+		}
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		lenVal, err = ebcdicEncode(contentLen, lenEncoder)
+		if err != nil {
+			return nil, err
+		}
+		if len(lenVal) > 3 {
+			return nil, ErrInvalidLengthHead
 		}
 	default:
-		return nil, errors.New(ErrInvalidLengthEncoder)
+		return nil, ErrInvalidLengthEncoder
 	}
 	return append(lenVal, val...), nil
 }
 
 // Load decode Lllvar field from bytes
 func (l *Lllvar) Load(raw []byte, encoder, lenEncoder, length int) (read int, err error) {
-	raw, err = UTF8ToWindows1252(raw)
-	if err != nil {
-		return 0, err
-	}
 	// parse length head:
 	var contentLen int
 	switch lenEncoder {
 	case ASCII:
 		read = 3
+		if len(raw) < read {
+			return 0, ErrBadRaw
+		}
 		contentLen, err = strconv.Atoi(string(raw[:read]))
 		if err != nil {
-			return 0, errors.New(ErrParseLengthFailed + ": " + string(raw[:3]))
+			return 0, errParseLength(raw[:read])
 		}
 	case rBCD:
 		fallthrough
 	case BCD:
 		read = 2
+		if len(raw) < read {
+			return 0, ErrBadRaw
+		}
 		contentLen, err = strconv.Atoi(string(bcdr2Ascii(raw[:read], 3)))
 		if err != nil {
-			return 0, errors.New(ErrParseLengthFailed + ": " + string(raw[:2]))
+			return 0, errParseLength(raw[:read])
+		}
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		read = 3
+		if len(raw) < read {
+			return 0, ErrBadRaw
+		}
+		var lenVal []byte
+		lenVal, err = ebcdicDecode(raw[:read], lenEncoder)
+		if err != nil {
+			return 0, err
+		}
+		contentLen, err = strconv.Atoi(string(lenVal))
+		if err != nil {
+			return 0, errParseLength(lenVal)
 		}
 	default:
-		return 0, errors.New(ErrInvalidLengthEncoder)
+		return 0, ErrInvalidLengthEncoder
 	}
 	if len(raw) < (read + contentLen) {
-		return 0, errors.New(ErrBadRaw)
+		return 0, ErrBadRaw
 	}
 	// parse body:
-	l.Value = raw[read : read+contentLen]
-	read += contentLen
-	if encoder != ASCII {
-		return 0, errors.New(ErrInvalidEncoder)
+	switch encoder {
+	case ASCII, CP1252, LATIN1, UTF8, BINARY:
+		l.Value, err = charsetDecode(raw[read:read+contentLen], encoder)
+		if err != nil {
+			return 0, err
+		}
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		l.Value, err = ebcdicDecode(raw[read:read+contentLen], encoder)
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, ErrInvalidEncoder
 	}
+	read += contentLen
 
 	return read, nil
 }
@@ -5,13 +5,12 @@
 package iso8583
 
 import (
-	"errors"
 	"fmt"
 	"strconv"
 )
 
 // A Llnumeric contains numeric value only in non-fix length, contains length in first 2 symbols. It holds numeric
-// value as a string. Supportted encoder are ascii, bcd and rbcd. Length is
+// value as a string. Supportted encoder are ascii, bcd, rbcd and ebcdic. Length is
 // required for marshaling and unmarshalling.
 type Llnumeric struct {
 	Value string
@@ -31,18 +30,24 @@ func (l *Llnumeric) IsEmpty() bool {
 func (l *Llnumeric) Bytes(encoder, lenEncoder, length int) ([]byte, error) {
 	raw := []byte(l.Value)
 	if length != -1 && len(raw) > length {
-		return nil, fmt.Errorf(ErrValueTooLong, "Llnumeric", length, len(raw))
+		return nil, errValueTooLong("Llnumeric", length, len(raw))
 	}
 
 	val := raw
+	var err error
 	switch encoder {
 	case ASCII:
 	case BCD:
-		val = lbcd(raw)
+		val, err = lbcd(raw)
 	case rBCD:
-		val = rbcd(raw)
+		val, err = rbcd(raw)
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		val, err = ebcdicEncode(raw, encoder)
 	default:
-		return nil, errors.New(ErrInvalidEncoder)
+		return nil, ErrInvalidEncoder
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	lenStr := fmt.Sprintf("%02d", len(raw)) // length of digital characters
@@ -52,17 +57,28 @@ func (l *Llnumeric) Bytes(encoder, lenEncoder, length int) ([]byte, error) {
 	case ASCII:
 		lenVal = contentLen
 		if len(lenVal) > 2 {
-			return nil, errors.New(ErrInvalidLengthHead)
+			return nil, ErrInvalidLengthHead
 		}
 	case rBCD:
 		fallthrough
 	case BCD:
-		lenVal = rbcd(contentLen)
+		lenVal, err = rbcd(contentLen)
+		if err != nil {
+			return nil, err
+		}
 		if len(lenVal) > 1 || len(contentLen) > 3 {
-			return nil, errors.New(ErrInvalidLengthHead)
+			return nil, ErrInvalidLengthHead
+		}
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		lenVal, err = ebcdicEncode(contentLen, lenEncoder)
+		if err != nil {
+			return nil, err
+		}
+		if len(lenVal) > 2 {
+			return nil, ErrInvalidLengthHead
 		}
 	default:
-		return nil, errors.New(ErrInvalidLengthEncoder)
+		return nil, ErrInvalidLengthEncoder
 	}
 	return append(lenVal, val...), nil
 }
@@ -74,27 +90,47 @@ func (l *Llnumeric) Load(raw []byte, encoder, lenEncoder, length int) (read int,
 	switch lenEncoder {
 	case ASCII:
 		read = 2
+		if len(raw) < read {
+			return 0, ErrBadRaw
+		}
 		contentLen, err = strconv.Atoi(string(raw[:read]))
 		if err != nil {
-			return 0, errors.New(ErrParseLengthFailed + ": " + string(raw[:2]))
+			return 0, errParseLength(raw[:read])
 		}
 	case rBCD:
 		fallthrough
 	case BCD:
 		read = 1
+		if len(raw) < read {
+			return 0, ErrBadRaw
+		}
 		contentLen, err = strconv.Atoi(string(bcdr2Ascii(raw[:read], 2)))
 		if err != nil {
-			return 0, errors.New(ErrParseLengthFailed + ": " + string(raw[0]))
+			return 0, errParseLength(raw[:read])
+		}
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		read = 2
+		if len(raw) < read {
+			return 0, ErrBadRaw
+		}
+		var lenVal []byte
+		lenVal, err = ebcdicDecode(raw[:read], lenEncoder)
+		if err != nil {
+			return 0, err
+		}
+		contentLen, err = strconv.Atoi(string(lenVal))
+		if err != nil {
+			return 0, errParseLength(lenVal)
 		}
 	default:
-		return 0, errors.New(ErrInvalidLengthEncoder)
+		return 0, ErrInvalidLengthEncoder
 	}
 
 	// parse body:
 	switch encoder {
 	case ASCII:
 		if len(raw) < (read + contentLen) {
-			return 0, errors.New(ErrBadRaw)
+			return 0, ErrBadRaw
 		}
 		l.Value = string(raw[read : read+contentLen])
 		read += contentLen
@@ -103,12 +139,23 @@ func (l *Llnumeric) Load(raw []byte, encoder, lenEncoder, length int) (read int,
 	case BCD:
 		bcdLen := (contentLen + 1) / 2
 		if len(raw) < (read + bcdLen) {
-			return 0, errors.New(ErrBadRaw)
+			return 0, ErrBadRaw
 		}
 		l.Value = string(bcdl2Ascii(raw[read:read+bcdLen], contentLen))
 		read += bcdLen
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		if len(raw) < (read + contentLen) {
+			return 0, ErrBadRaw
+		}
+		var val []byte
+		val, err = ebcdicDecode(raw[read:read+contentLen], encoder)
+		if err != nil {
+			return 0, err
+		}
+		l.Value = string(val)
+		read += contentLen
 	default:
-		return 0, errors.New(ErrInvalidEncoder)
+		return 0, ErrInvalidEncoder
 	}
 	return read, nil
 }
@@ -6,11 +6,11 @@ package iso8583
 
 import (
 	"encoding/hex"
-	"errors"
 	"fmt"
-	"reflect"
 	"strconv"
 	"strings"
+
+	"github.com/moov-io/iso8583/pkg/lib"
 )
 
 const (
@@ -20,43 +20,64 @@ const (
 	TagEncode string = "encode"
 	// TagLength defines the data encoding length
 	TagLength string = "length"
+	// TagType defines the data field type of native Go values
+	TagType string = "type"
+	// TagFormat defines the date and time format of time.Time values
+	TagFormat string = "format"
 )
 
-type fieldInfo struct {
-	Index     int
-	Encode    int
-	LenEncode int
-	Length    int
-	//Field are ISO-defined data elements
-	Field DataField
-}
-
 // Message is structure for ISO 8583 message encode and decode
 type Message struct {
+	// Header precedes the MTI, nil for messages without header. It's
+	// emitted by Bytes and populated by Load.
+	Header Header
 	// Mti is the Message Type Indicator
 	Mti          string
 	MtiEncode    int
 	SecondBitmap bool
 	ASCIIBitmap  bool
-	// Data elements are the individual fields carrying the transaction information
+	// Charset of ascii encoded character fields, one of ASCII (Windows-1252),
+	// CP1252, LATIN1, UTF8 and BINARY. Fields with another encoder in their
+	// tag keep it.
+	Charset int
+	// Data elements are the individual fields carrying the transaction
+	// information: a template struct, or lib.Iso8583Message of messages
+	// decoded with a specification. The latter carries MTI and bitmap too.
 	Data interface{}
 }
 
 // NewMessage creates new Message structure
 func NewMessage(mti string, data interface{}) *Message {
-	return &Message{mti, ASCII, false, false, data}
+	return &Message{Mti: mti, Data: data}
 }
 
-// Bytes marshall Message to bytes
-func (m *Message) Bytes() (ret []byte, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = errors.New("Critical error:" + fmt.Sprint(r))
-			ret = nil
-		}
-	}()
+// Bytes marshall Message to bytes. Errors of data fields are given as
+// *FieldError, problems of the template as *TemplateError. SecondBitmap is
+// switched on when the message has fields above 64. Fields 1 and 65 are
+// reserved for the secondary and tertiary bitmaps and can't be populated.
+// BeforePack and Validate hooks of the template are called before the
+// fields are encoded.
+func (m *Message) Bytes() ([]byte, error) {
+	if err := m.beforePack(); err != nil {
+		return nil, err
+	}
+	ret := make([]byte, 0)
 
-	ret = make([]byte, 0)
+	// generate header:
+	if m.Header != nil {
+		h, err := m.Header.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, h...)
+	}
+	if generic, ok := m.Data.(lib.Iso8583Message); ok {
+		b, err := generic.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		return append(ret, b...), nil
+	}
 
 	// generate MTI:
 	mtiBytes, err := m.encodeMti()
@@ -66,48 +87,71 @@ func (m *Message) Bytes() (ret []byte, err error) {
 	ret = append(ret, mtiBytes...)
 
 	// generate bitmap and fields:
-	fields := parseFields(m.Data)
+	v, tpl, err := templateValue(m.Data)
+	if err != nil {
+		return nil, err
+	}
 
-	byteNum := 8
-	if m.SecondBitmap {
-		byteNum = 16
+	// if field is empty, then we can't add it to bitmap
+	fields := make([]DataField, len(tpl.fields))
+	max := 0
+	for i, info := range tpl.fields {
+		field, err := info.dataField(v)
+		if err != nil {
+			return nil, err
+		}
+		if field != nil && !field.IsEmpty() {
+			fields[i] = field
+			max = info.Index
+		}
 	}
+
+	byteNum := m.bitmapSize(max)
 	bitmap := make([]byte, byteNum)
 	data := make([]byte, 0, 512)
 
-	for byteIndex := 0; byteIndex < byteNum; byteIndex++ {
-		for bitIndex := 0; bitIndex < 8; bitIndex++ {
-
-			i := byteIndex*8 + bitIndex + 1
-
-			// if we need second bitmap (additional 8 bytes) - set first bit in first bitmap
-			if m.SecondBitmap && i == 1 {
-				step := uint(7 - bitIndex)
-				bitmap[byteIndex] |= (0x01 << step)
-			}
-
-			if info, ok := fields[i]; ok {
+	// if we need second bitmap (additional 8 bytes) - set first bit in first bitmap,
+	// if we need third bitmap (additional 8 bytes) - set first bit in second bitmap
+	if byteNum > 8 {
+		bitmap[0] |= 0x80
+	}
+	if byteNum > 16 {
+		bitmap[8] |= 0x80
+	}
 
-				// if field is empty, then we can't add it to bitmap
-				if info.Field.IsEmpty() {
-					continue
-				}
+	// offset of the first field in message bytes
+	start := len(ret) + byteNum
+	if m.ASCIIBitmap {
+		start += byteNum
+	}
+	for i, field := range fields {
+		info := tpl.fields[i]
+		if field == nil || info.Index < 1 || info.Index > byteNum*8 {
+			continue
+		}
+		if info.Index == 1 || info.Index == 65 {
+			return nil, &FieldError{info.Index, start + len(data), fieldType(field), ErrReservedField}
+		}
 
-				// mark 1 in bitmap:
-				step := uint(7 - bitIndex)
-				bitmap[byteIndex] |= (0x01 << step)
-				// append data:
-				d, err := info.Field.Bytes(info.Encode, info.LenEncode, info.Length)
-				if err != nil {
-					return nil, err
-				}
-				data = append(data, d...)
-			}
+		// mark 1 in bitmap:
+		bitmap[(info.Index-1)/8] |= 0x80 >> uint((info.Index-1)%8)
+		// append data:
+		d, err := field.Bytes(m.fieldEncoder(info, field), info.LenEncode, info.Length)
+		if err != nil {
+			return nil, &FieldError{info.Index, start + len(data), fieldType(field), err}
 		}
+		data = append(data, d...)
 	}
 
 	if m.ASCIIBitmap {
 		bitmap = []byte(strings.ToUpper(hex.EncodeToString(bitmap)))
+		// character bitmap of EBCDIC messages is encoded with the code page of MTI
+		if isEBCDIC(m.MtiEncode) {
+			bitmap, err = ebcdicEncode(bitmap, m.MtiEncode)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 	ret = append(ret, bitmap...)
 	ret = append(ret, data...)
@@ -115,82 +159,53 @@ func (m *Message) Bytes() (ret []byte, err error) {
 	return ret, nil
 }
 
+// bitmapSize returns the number of bitmap bytes required by the highest
+// populated field. SecondBitmap is switched on when it is above 64.
+func (m *Message) bitmapSize(max int) int {
+	if max > 64 {
+		m.SecondBitmap = true
+	}
+	switch {
+	case max > 128:
+		return 24
+	case m.SecondBitmap:
+		return 16
+	}
+	return 8
+}
+
+// fieldEncoder returns encoder of field f. Charset of the message replaces
+// the ascii encoder of character fields.
+func (m *Message) fieldEncoder(f *fieldInfo, field DataField) int {
+	if f.Encode == ASCII && m.Charset != ASCII && isCharField(field) {
+		return m.Charset
+	}
+	return f.Encode
+}
+
 func (m *Message) encodeMti() ([]byte, error) {
 	if m.Mti == "" {
-		return nil, errors.New("MTI is required")
+		return nil, ErrMtiRequired
 	}
 	if len(m.Mti) != 4 {
-		return nil, errors.New("MTI is invalid")
+		return nil, ErrInvalidMti
 	}
 
 	// check MTI, it must contain only digits
 	if _, err := strconv.Atoi(m.Mti); err != nil {
-		return nil, errors.New("MTI is invalid")
+		return nil, ErrInvalidMti
 	}
 
 	switch m.MtiEncode {
 	case BCD:
-		return bcd([]byte(m.Mti)), nil
+		return bcd([]byte(m.Mti))
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		return ebcdicEncode([]byte(m.Mti), m.MtiEncode)
 	default:
 		return []byte(m.Mti), nil
 	}
 }
 
-func parseFields(msg interface{}) map[int]*fieldInfo {
-	fields := make(map[int]*fieldInfo)
-
-	v := reflect.Indirect(reflect.ValueOf(msg))
-	if v.Kind() != reflect.Struct {
-		panic("data must be a struct")
-	}
-	for i := 0; i < v.NumField(); i++ {
-		if isPtrOrInterface(v.Field(i).Kind()) && v.Field(i).IsNil() {
-			continue
-		}
-
-		sf := v.Type().Field(i)
-		if sf.Tag == "" || sf.Tag.Get(TagField) == "" {
-			continue
-		}
-
-		index, err := strconv.Atoi(sf.Tag.Get(TagField))
-		if err != nil {
-			panic("value of field must be numeric")
-		}
-
-		encode := 0
-		lenEncode := 0
-		if raw := sf.Tag.Get(TagEncode); raw != "" {
-			enc := strings.Split(raw, ",")
-			if len(enc) == 2 {
-				lenEncode = parseEncodeStr(enc[0])
-				encode = parseEncodeStr(enc[1])
-			} else {
-				encode = parseEncodeStr(enc[0])
-			}
-		}
-
-		length := -1
-		if l := sf.Tag.Get(TagLength); l != "" {
-			length, err = strconv.Atoi(l)
-			if err != nil {
-				panic("value of length must be numeric")
-			}
-		}
-
-		field, ok := v.Field(i).Interface().(DataField)
-		if !ok {
-			panic("field must be Iso8583Type")
-		}
-		fields[index] = &fieldInfo{index, encode, lenEncode, length, field}
-	}
-	return fields
-}
-
-func isPtrOrInterface(k reflect.Kind) bool {
-	return k == reflect.Interface || k == reflect.Ptr
-}
-
 func parseEncodeStr(str string) int {
 	switch str {
 	case "ascii":
@@ -201,61 +216,73 @@ func parseEncodeStr(str string) int {
 		return BCD
 	case "rbcd":
 		return rBCD
+	case "ebcdic", "ebcdic037":
+		return EBCDIC
+	case "ebcdic273":
+		return EBCDIC273
+	case "ebcdic1140":
+		return EBCDIC1140
+	case "ebcdic1141":
+		return EBCDIC1141
+	case "bin":
+		return BIN
+	case "cp1252":
+		return CP1252
+	case "latin1", "iso8859-1":
+		return LATIN1
+	case "utf8", "utf-8":
+		return UTF8
+	case "binary":
+		return BINARY
 	}
 	return -1
 }
 
-// Load unmarshall Message from bytes
+// Load unmarshall Message from bytes. Errors of data fields are given as
+// *FieldError, problems of the template as *TemplateError. AfterUnpack and
+// Validate hooks of the template are called after the fields are decoded.
 func (m *Message) Load(raw []byte) (err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = errors.New("Critical error:" + fmt.Sprint(r))
-		}
-	}()
-
-	if m.Mti == "" {
-		m.Mti, err = decodeMti(raw, m.MtiEncode)
+	start := 0
+	if m.Header != nil {
+		start, err = m.Header.Load(raw)
 		if err != nil {
 			return err
 		}
 	}
-	start := 4
-	if m.MtiEncode == BCD {
-		start = 2
-	}
-
-	fields := parseFields(m.Data)
-
-	byteNum := 8
-	var bitByte []byte
-
-	if m.ASCIIBitmap {
-		b, err := hex.DecodeString(fmt.Sprintf("%s", raw[start:start+byteNum*2]))
-		if err != nil {
-			return fmt.Errorf("bitmap isn't ASCII formatted: %s", err)
-		}
-
-		if b[0]&0x80 == 0x80 {
-			m.SecondBitmap = true
-			byteNum = 16
+	if generic, ok := m.Data.(lib.Iso8583Message); ok {
+		if _, err := generic.Load(raw[start:]); err != nil {
+			return err
 		}
-
-		bitByte, err = hex.DecodeString(fmt.Sprintf("%s", raw[start:start+byteNum*2]))
+		m.Mti = generic.GetMti().String()
+		return nil
+	}
+	if m.Mti == "" {
+		m.Mti, err = decodeMti(raw[start:], m.MtiEncode)
 		if err != nil {
 			return err
 		}
-		start += byteNum * 2
+	}
+	if m.MtiEncode == BCD {
+		start += 2
 	} else {
-		if raw[start]&0x80 == 0x80 {
-			// 1st bit == 1
-			m.SecondBitmap = true
-			byteNum = 16
-		}
-		bitByte = raw[start : start+byteNum]
-		start += byteNum
+		start += 4
+	}
+	if len(raw) < start {
+		return ErrBadMtiRaw
+	}
 
+	v, tpl, err := templateValue(m.Data)
+	if err != nil {
+		return err
 	}
 
+	bitByte, read, err := m.decodeBitmap(raw[start:])
+	if err != nil {
+		return err
+	}
+	start += read
+	byteNum := len(bitByte)
+
 	for byteIndex := 0; byteIndex < byteNum; byteIndex++ {
 		for bitIndex := 0; bitIndex < 8; bitIndex++ {
 			step := uint(7 - bitIndex)
@@ -264,20 +291,71 @@ func (m *Message) Load(raw []byte) (err error) {
 			}
 
 			i := byteIndex*8 + bitIndex + 1
-			if i == 1 {
-				// field 1 is the second bitmap
+			if i == 1 || (i == 65 && byteNum > 16) {
+				// field 1 is the second bitmap, field 65 is the third bitmap
 				continue
 			}
-			f, ok := fields[i]
+			f, ok := tpl.byIndex[i]
 			if !ok {
-				return fmt.Errorf("field %d not defined", i)
+				return &FieldError{Index: i, Offset: start, Err: ErrUndefinedField}
+			}
+			field, err := f.dataField(v)
+			if err != nil {
+				return err
 			}
-			l, err := f.Field.Load(raw[start:], f.Encode, f.LenEncode, f.Length)
+			if field == nil {
+				return &FieldError{Index: i, Offset: start, Err: ErrUndefinedField}
+			}
+			l, err := field.Load(raw[start:], m.fieldEncoder(f, field), f.LenEncode, f.Length)
 			if err != nil {
-				return fmt.Errorf("field %d: %s", i, err)
+				return &FieldError{i, start, fieldType(field), err}
 			}
 			start += l
 		}
 	}
-	return nil
+	return m.afterUnpack()
+}
+
+// decodeBitmap reads primary, secondary and tertiary bitmaps from raw. It
+// returns the bitmap bytes and the number of bytes actually read.
+func (m *Message) decodeBitmap(raw []byte) ([]byte, int, error) {
+	bitmap := make([]byte, 0, 24)
+	read := 0
+	for {
+		var b []byte
+		if m.ASCIIBitmap {
+			if len(raw) < read+16 {
+				return nil, 0, ErrBadRaw
+			}
+			chars := raw[read : read+16]
+			if isEBCDIC(m.MtiEncode) {
+				var err error
+				chars, err = ebcdicDecode(chars, m.MtiEncode)
+				if err != nil {
+					return nil, 0, err
+				}
+			}
+			decoded, err := hex.DecodeString(string(chars))
+			if err != nil {
+				return nil, 0, fmt.Errorf("%w: bitmap isn't ASCII formatted: %s", ErrBadRaw, err)
+			}
+			b = decoded
+			read += 16
+		} else {
+			if len(raw) < read+8 {
+				return nil, 0, ErrBadRaw
+			}
+			b = raw[read : read+8]
+			read += 8
+		}
+		bitmap = append(bitmap, b...)
+
+		// the first bit of the primary (secondary) bitmap indicates
+		// the presence of the secondary (tertiary) bitmap
+		if b[0]&0x80 != 0x80 || len(bitmap) == 24 {
+			break
+		}
+	}
+	m.SecondBitmap = len(bitmap) > 8
+	return bitmap, read, nil
 }
@@ -0,0 +1,85 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package iso8583
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ISO 8583 versions, the first digit of MTI
+const (
+	Version1987 = 0
+	Version1993 = 1
+	Version2003 = 2
+)
+
+// MTI is the Message Type Indicator split into its four digits
+type MTI struct {
+	// Version of ISO 8583, for example Version1987
+	Version int
+	// Class of the message, for example 1 for authorization
+	Class int
+	// Function of the message, for example 0 for request and 1 for response
+	Function int
+	// Origin of the message, for example 0 for acquirer
+	Origin int
+}
+
+// ParseMTI splits 4 digit MTI into its components
+func ParseMTI(mti string) (MTI, error) {
+	if len(mti) != 4 {
+		return MTI{}, fmt.Errorf("%w: must be a 4 digit numeric field", ErrInvalidMti)
+	}
+	var d [4]int
+	for i := 0; i < 4; i++ {
+		if mti[i] < '0' || mti[i] > '9' {
+			return MTI{}, fmt.Errorf("%w: must be a 4 digit numeric field", ErrInvalidMti)
+		}
+		d[i] = int(mti[i] - '0')
+	}
+	return MTI{d[0], d[1], d[2], d[3]}, nil
+}
+
+// String returns 4 digit MTI
+func (m MTI) String() string {
+	return fmt.Sprintf("%d%d%d%d", m.Version, m.Class, m.Function, m.Origin)
+}
+
+// mtiPattern is MTI registered with x for any digit, for example 01x0
+type mtiPattern string
+
+// parseMTIPattern checks 4 digit MTI, where x stands for any digit
+func parseMTIPattern(mti string) (mtiPattern, error) {
+	mti = strings.ToLower(mti)
+	if len(mti) != 4 {
+		return "", fmt.Errorf("%w: must be a 4 digit numeric field", ErrInvalidMti)
+	}
+	for i := 0; i < 4; i++ {
+		if (mti[i] < '0' || mti[i] > '9') && mti[i] != 'x' {
+			return "", fmt.Errorf("%w: must be a 4 digit numeric field or pattern with x", ErrInvalidMti)
+		}
+	}
+	return mtiPattern(mti), nil
+}
+
+// match reports whether MTI matches the pattern
+func (p mtiPattern) match(mti string) bool {
+	if len(mti) != len(p) {
+		return false
+	}
+	for i := 0; i < len(p); i++ {
+		if p[i] != 'x' && p[i] != mti[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// wildcards returns the number of x in the pattern, patterns with fewer
+// wildcards are more specific
+func (p mtiPattern) wildcards() int {
+	return strings.Count(string(p), "x")
+}
@@ -0,0 +1,322 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package iso8583
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	dataFieldType       = reflect.TypeOf((*DataField)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+var errNotPointer = &TemplateError{Err: errors.New("template must be a pointer to struct")}
+
+// fieldTypes maps values of the type tag to the DataField used on the wire
+var fieldTypes = map[string]func() DataField{
+	"numeric":      func() DataField { return NewNumeric("") },
+	"alphanumeric": func() DataField { return NewAlphanumeric("") },
+	"binary":       func() DataField { return NewBinary(nil) },
+	"llvar":        func() DataField { return NewLlvar(nil) },
+	"lllvar":       func() DataField { return NewLllvar(nil) },
+	"l8var":        func() DataField { return NewL8var(nil) },
+	"llnumeric":    func() DataField { return NewLlnumeric("") },
+	"lllnumeric":   func() DataField { return NewLllnumeric("") },
+	"llbin":        func() DataField { return NewLlbin(nil) },
+	"lllbin":       func() DataField { return NewLllbin(nil) },
+}
+
+// timeLayout converts ISO 8583 date and time formats (for ex. MMDDhhmmss)
+// into Go time layout
+var timeLayout = strings.NewReplacer(
+	"YYYY", "2006",
+	"YY", "06",
+	"MM", "01",
+	"DD", "02",
+	"hh", "15",
+	"mm", "04",
+	"ss", "05",
+)
+
+// nativeField adapts a native Go value of a template (string, integers,
+// []byte, time.Time and encoding.TextMarshaler) to DataField. The wire
+// representation is chosen with the type tag.
+type nativeField struct {
+	value     reflect.Value
+	field     DataField
+	format    string
+	omitEmpty bool
+}
+
+// parseTypeTag splits type tag into the field type and omitempty option,
+// for example "numeric,omitempty"
+func parseTypeTag(tag string) (string, bool) {
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			return parts[0], true
+		}
+	}
+	return parts[0], false
+}
+
+// checkNativeField checks that native struct field sf can be used with
+// type tag typ
+func checkNativeField(sf reflect.StructField, typ, format string) *TemplateError {
+	if _, ok := fieldTypes[typ]; !ok {
+		return templateError(sf, "unknown field type: "+typ)
+	}
+	if !isNativeType(sf.Type) {
+		return templateError(sf, "unsupported native type: "+sf.Type.String())
+	}
+	if indirectType(sf.Type) == timeType && format == "" {
+		return templateError(sf, "format is required for time.Time")
+	}
+	return nil
+}
+
+// IsEmpty check native field for empty value. Pointer fields are empty
+// when nil, other fields when they have zero value and omitempty option
+func (n *nativeField) IsEmpty() bool {
+	if n.value.Kind() == reflect.Ptr {
+		return n.value.IsNil()
+	}
+	return n.omitEmpty && n.value.IsZero()
+}
+
+// Bytes encode native field to bytes
+func (n *nativeField) Bytes(encoder, lenEncoder, length int) ([]byte, error) {
+	v := reflect.Indirect(n.value)
+	if isNumericField(n.field) && isSigned(v.Kind()) && v.Int() < 0 {
+		return nil, fmt.Errorf("%w: negative value %d", ErrInvalidValue, v.Int())
+	}
+	text, err := marshalText(v, n.format)
+	if err != nil {
+		return nil, err
+	}
+	setFieldText(n.field, text)
+	return n.field.Bytes(encoder, lenEncoder, length)
+}
+
+// Load decode native field from bytes
+func (n *nativeField) Load(raw []byte, encoder, lenEncoder, length int) (int, error) {
+	read, err := n.field.Load(raw, encoder, lenEncoder, length)
+	if err != nil {
+		return 0, err
+	}
+	if !n.value.CanSet() {
+		return 0, errNotPointer
+	}
+	v := n.value
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	text := fieldText(n.field)
+	if _, ok := n.field.(*Alphanumeric); ok {
+		// Alphanumeric is padded on the left
+		text = bytes.TrimLeft(text, " ")
+	}
+	if err := unmarshalText(v, text, n.format); err != nil {
+		return 0, err
+	}
+	return read, nil
+}
+
+func isNumericField(f DataField) bool {
+	switch f.(type) {
+	case *Numeric, *Llnumeric, *Lllnumeric:
+		return true
+	}
+	return false
+}
+
+func isSigned(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+func isNativeType(t reflect.Type) bool {
+	t = indirectType(t)
+	if t == timeType {
+		return true
+	}
+	if t.Implements(textMarshalerType) && reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+func marshalText(v reflect.Value, format string) ([]byte, error) {
+	if v.Type() == timeType {
+		return []byte(v.Interface().(time.Time).Format(timeLayout.Replace(format))), nil
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		return m.MarshalText()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return []byte(v.String()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(nil, v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.AppendUint(nil, v.Uint(), 10), nil
+	case reflect.Slice:
+		return v.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unsupported native type: %s", v.Type())
+}
+
+func unmarshalText(v reflect.Value, text []byte, format string) error {
+	if v.Type() == timeType {
+		t, err := time.Parse(timeLayout.Replace(format), string(text))
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrBadRaw, err)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText(text)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(text))
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(strings.TrimSpace(string(text)), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrBadRaw, err)
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(strings.TrimSpace(string(text)), 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrBadRaw, err)
+		}
+		v.SetUint(i)
+		return nil
+	case reflect.Slice:
+		v.SetBytes(append([]byte(nil), text...))
+		return nil
+	}
+	return fmt.Errorf("unsupported native type: %s", v.Type())
+}
+
+func setFieldText(f DataField, text []byte) {
+	switch f := f.(type) {
+	case *Numeric:
+		f.Value = string(text)
+	case *Alphanumeric:
+		f.Value = string(text)
+	case *Llnumeric:
+		f.Value = string(text)
+	case *Lllnumeric:
+		f.Value = string(text)
+	case *Binary:
+		f.Value = text
+	case *Llvar:
+		f.Value = text
+	case *Lllvar:
+		f.Value = text
+	case *L8var:
+		f.Value = text
+	case *Llbin:
+		f.Value = text
+	case *Lllbin:
+		f.Value = text
+	}
+}
+
+func fieldText(f DataField) []byte {
+	switch f := f.(type) {
+	case *Numeric:
+		return []byte(f.Value)
+	case *Alphanumeric:
+		return []byte(f.Value)
+	case *Llnumeric:
+		return []byte(f.Value)
+	case *Lllnumeric:
+		return []byte(f.Value)
+	case *Binary:
+		return f.Value
+	case *Llvar:
+		return f.Value
+	case *Lllvar:
+		return f.Value
+	case *L8var:
+		return f.Value
+	case *Llbin:
+		return f.Value
+	case *Lllbin:
+		return f.Value
+	}
+	return nil
+}
+
+// Marshal returns the ISO 8583 encoding of template v with the given MTI.
+// Fields of v are either DataField types or native Go types (string,
+// integers, []byte, time.Time and encoding.TextMarshaler) with a type tag
+// that chooses the wire representation, for example:
+//
+//	type Auth struct {
+//		PAN    string    `field:"2" type:"llnumeric" length:"19"`
+//		Amount int64     `field:"4" type:"numeric" length:"12"`
+//		Time   time.Time `field:"7" type:"numeric" format:"MMDDhhmmss"`
+//	}
+//
+// Nil pointers are omitted from the message, as well as zero values of
+// fields with omitempty option, for example `type:"numeric,omitempty"`.
+// Negative integers can't be sent in numeric types.
+func Marshal(mti string, v interface{}) ([]byte, error) {
+	return NewMessage(mti, v).Bytes()
+}
+
+// Unmarshal parses ISO 8583 encoded data and stores the fields in the
+// template pointed to by v. See Marshal for supported field types.
+func Unmarshal(raw []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errNotPointer
+	}
+	if rv.Elem().Kind() != reflect.Struct {
+		return &TemplateError{Err: errors.New("data must be a struct")}
+	}
+	if err := initStruct(rv.Elem().Type(), rv); err != nil {
+		return err
+	}
+	return NewMessage("", v).Load(raw)
+}
@@ -5,13 +5,11 @@
 package iso8583
 
 import (
-	"errors"
-	"fmt"
 	"strings"
 )
 
 // A Numeric contains numeric value only in fix length. It holds numeric
-// value as a string. Supportted encoder are ascii, bcd and rbcd. Length is
+// value as a string. Supportted encoder are ascii, bcd, rbcd and ebcdic. Length is
 // required for marshaling and unmarshaling.
 type Numeric struct {
 	Value string
@@ -31,7 +29,7 @@ func (n *Numeric) IsEmpty() bool {
 func (n *Numeric) Bytes(encoder, lenEncoder, length int) ([]byte, error) {
 	val := []byte(n.Value)
 	if length == -1 {
-		return nil, errors.New(ErrMissingLength)
+		return nil, ErrMissingLength
 	}
 	// if encoder == rBCD then length can be, for example, 3,
 	// but value can be, for example, "0631" (after decode from rBCD, because BCD use 1 byte for 2 digits),
@@ -44,50 +42,62 @@ func (n *Numeric) Bytes(encoder, lenEncoder, length int) ([]byte, error) {
 	}
 
 	if len(val) > length {
-		return nil, fmt.Errorf(ErrValueTooLong, "Numeric", length, len(val))
+		return nil, errValueTooLong("Numeric", length, len(val))
 	}
 	if len(val) < length {
 		val = append([]byte(strings.Repeat("0", length-len(val))), val...)
 	}
 	switch encoder {
 	case BCD:
-		return lbcd(val), nil
+		return lbcd(val)
 	case rBCD:
-		return rbcd(val), nil
+		return rbcd(val)
 	case ASCII:
 		return val, nil
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		return ebcdicEncode(val, encoder)
 	default:
-		return nil, errors.New(ErrInvalidEncoder)
+		return nil, ErrInvalidEncoder
 	}
 }
 
 // Load decode Numeric field from bytes
 func (n *Numeric) Load(raw []byte, encoder, lenEncoder, length int) (int, error) {
 	if length == -1 {
-		return 0, errors.New(ErrMissingLength)
+		return 0, ErrMissingLength
 	}
 	switch encoder {
 	case BCD:
 		l := (length + 1) / 2
 		if len(raw) < l {
-			return 0, errors.New(ErrBadRaw)
+			return 0, ErrBadRaw
 		}
 		n.Value = string(bcdl2Ascii(raw[:l], length))
 		return l, nil
 	case rBCD:
 		l := (length + 1) / 2
 		if len(raw) < l {
-			return 0, errors.New(ErrBadRaw)
+			return 0, ErrBadRaw
 		}
 		n.Value = string(bcdr2Ascii(raw[0:l], length))
 		return l, nil
 	case ASCII:
 		if len(raw) < length {
-			return 0, errors.New(ErrBadRaw)
+			return 0, ErrBadRaw
 		}
 		n.Value = string(raw[:length])
 		return length, nil
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		if len(raw) < length {
+			return 0, ErrBadRaw
+		}
+		val, err := ebcdicDecode(raw[:length], encoder)
+		if err != nil {
+			return 0, err
+		}
+		n.Value = string(val)
+		return length, nil
 	default:
-		return 0, errors.New(ErrInvalidEncoder)
+		return 0, ErrInvalidEncoder
 	}
 }
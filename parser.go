@@ -8,42 +8,135 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+
+	"github.com/moov-io/iso8583/pkg/lib"
+	"github.com/moov-io/iso8583/pkg/utils"
 )
 
 // Parser for ISO 8583 messages
 type Parser struct {
-	messages  map[string]reflect.Type
+	messages map[string]reflect.Type
+	// templates of MTI patterns in order of registration
+	patterns  []registeredPattern
 	MtiEncode int
+	// Charset of ascii encoded character fields of parsed messages
+	Charset int
+	// Header creates the header of parsed messages, nil for messages
+	// without header
+	Header func() Header
+	// Factory chooses the template of MTIs without registered template or
+	// pattern. It returns a pointer to a template struct, or nil when it
+	// has no template for the MTI.
+	Factory func(mti MTI) interface{}
+	// Fallback decodes messages of MTIs without registered template into
+	// lib.Iso8583Message, nil for failing them with ErrUnknownMti
+	Fallback *utils.Specification
 }
 
-// Register MTI
-func (p *Parser) Register(mti string, tpl interface{}) (err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = errors.New("Critical error:" + fmt.Sprint(r))
-		}
-	}()
+type registeredPattern struct {
+	pattern mtiPattern
+	tp      reflect.Type
+}
+
+// Register MTI. The MTI can be a pattern with x for any digit, for example
+// 01x0 for authorization requests and responses or x100 for all versions.
+// Exact MTIs take priority over patterns, and patterns with fewer x over
+// the others. The template is validated against the rules of its field
+// types, all problems found are given as TemplateErrors.
+func (p *Parser) Register(mti string, tpl interface{}) error {
+	pattern, err := parseMTIPattern(mti)
+	if err != nil {
+		return err
+	}
+	if tpl == nil {
+		return &TemplateError{Err: errors.New("data must be a struct")}
+	}
+	tp := reflect.TypeOf(tpl)
+	if tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+	// compile the template once, so it is reused by Parse
+	compiled, err := templateOf(tp)
+	if err != nil {
+		return err
+	}
+	if len(compiled.problems) > 0 {
+		return compiled.problems
+	}
 
-	if len(mti) != 4 {
-		return errors.New("MTI must be a 4 digit numeric field")
+	if pattern.wildcards() > 0 {
+		for i, r := range p.patterns {
+			if r.pattern == pattern {
+				p.patterns[i].tp = tp
+				return nil
+			}
+		}
+		p.patterns = append(p.patterns, registeredPattern{pattern, tp})
+		return nil
 	}
-	v := reflect.ValueOf(tpl)
-	// TODO do more check
 	if p.messages == nil {
 		p.messages = make(map[string]reflect.Type)
 	}
-	p.messages[mti] = reflect.Indirect(v).Type()
+	p.messages[string(pattern)] = tp
 
 	return nil
 }
 
+// template returns template type registered for MTI, exactly or by the
+// most specific pattern
+func (p *Parser) template(mti string) (reflect.Type, bool) {
+	if tp, ok := p.messages[mti]; ok {
+		return tp, true
+	}
+	var found *registeredPattern
+	for i, r := range p.patterns {
+		if r.pattern.match(mti) && (found == nil || r.pattern.wildcards() < found.pattern.wildcards()) {
+			found = &p.patterns[i]
+		}
+	}
+	if found == nil {
+		return nil, false
+	}
+	return found.tp, true
+}
+
+// factoryTemplate returns template chosen by Factory, nil if it has none
+func (p *Parser) factoryTemplate(mti string) (interface{}, error) {
+	if p.Factory == nil {
+		return nil, nil
+	}
+	m, err := ParseMTI(mti)
+	if err != nil {
+		return nil, err
+	}
+	tpl := p.Factory(m)
+	if tpl == nil {
+		return nil, nil
+	}
+	val := reflect.ValueOf(tpl)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return nil, errNotPointer
+	}
+	compiled, err := templateOf(val.Elem().Type())
+	if err != nil {
+		return nil, err
+	}
+	if len(compiled.problems) > 0 {
+		return nil, compiled.problems
+	}
+	if err := initStruct(val.Elem().Type(), val); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
 func decodeMti(raw []byte, encode int) (string, error) {
 	mtiLen := 4
 	if encode == BCD {
 		mtiLen = 2
 	}
 	if len(raw) < mtiLen {
-		return "", errors.New("bad MTI raw data")
+		return "", ErrBadMtiRaw
 	}
 
 	var mti string
@@ -52,46 +145,75 @@ func decodeMti(raw []byte, encode int) (string, error) {
 		mti = string(raw[:mtiLen])
 	case BCD:
 		mti = string(bcd2Ascii(raw[:mtiLen]))
+	case EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141:
+		b, err := ebcdicDecode(raw[:mtiLen], encode)
+		if err != nil {
+			return "", err
+		}
+		mti = string(b)
 	default:
-		return "", errors.New("invalid encode type")
+		return "", ErrInvalidMtiEncoder
 	}
 	return mti, nil
 }
 
-//Parse MTI
-func (p *Parser) Parse(raw []byte) (ret *Message, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = errors.New("Critical error:" + fmt.Sprint(r))
-			ret = nil
+// Parse MTI and decode the message with the template registered for it, or
+// chosen by Factory. Messages of other MTIs are decoded with the Fallback
+// specification, their Data is lib.Iso8583Message.
+func (p *Parser) Parse(raw []byte) (*Message, error) {
+	var header Header
+	start := 0
+	if p.Header != nil {
+		header = p.Header()
+		var err error
+		start, err = header.Load(raw)
+		if err != nil {
+			return nil, err
 		}
-	}()
-
-	mti, err := decodeMti(raw, p.MtiEncode)
+	}
+	mti, err := decodeMti(raw[start:], p.MtiEncode)
 	if err != nil {
 		return nil, err
 	}
 
-	tp, ok := p.messages[mti]
-	if !ok {
-		return nil, errors.New("no template registered for MTI: " + mti)
+	data, err := p.messageData(mti)
+	if err != nil {
+		return nil, err
 	}
-	tpl := reflect.New(tp)
-	initStruct(tp, tpl)
-	msg := NewMessage(mti, tpl.Interface())
+	msg := NewMessage(mti, data)
 	msg.MtiEncode = p.MtiEncode
+	msg.Charset = p.Charset
+	msg.Header = header
 	return msg, msg.Load(raw)
 }
 
-func initStruct(tp reflect.Type, val reflect.Value) {
-	for i := 0; i < tp.NumField(); i++ {
-		field := reflect.Indirect(val).Field(i)
-		fieldType := tp.Field(i)
+// messageData returns Data of message with MTI: a new template registered
+// for it or chosen by Factory, or lib.Iso8583Message of Fallback
+func (p *Parser) messageData(mti string) (interface{}, error) {
+	if tp, ok := p.template(mti); ok {
+		tpl := reflect.New(tp)
+		return tpl.Interface(), initStruct(tp, tpl)
+	}
+	if tpl, err := p.factoryTemplate(mti); tpl != nil || err != nil {
+		return tpl, err
+	}
+	if p.Fallback != nil {
+		return lib.NewISO8583Message(p.Fallback)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownMti, mti)
+}
 
-		// only initialize Ptr fields
-		if fieldType.Type.Kind() == reflect.Ptr {
-			fieldValue := reflect.New(fieldType.Type.Elem())
-			field.Set(fieldValue)
+func initStruct(tp reflect.Type, val reflect.Value) error {
+	tpl, err := templateOf(tp)
+	if err != nil {
+		return err
+	}
+	v := reflect.Indirect(val)
+	for _, i := range tpl.ptrFields {
+		// only initialize nil Ptr fields of DataField type
+		if field := v.Field(i); field.IsNil() {
+			field.Set(reflect.New(tp.Field(i).Type.Elem()))
 		}
 	}
+	return nil
 }
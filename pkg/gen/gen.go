@@ -0,0 +1,203 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package gen generates struct templates of the iso8583 package from
+// specifications of pkg/lib, so both describe the same messages.
+package gen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+
+	"github.com/moov-io/iso8583/pkg/utils"
+)
+
+// ErrNoMessageTypes is given when the specification has no message types
+var ErrNoMessageTypes = errors.New("specification has no message types")
+
+// encoders maps encodings of the specification to encode tag values
+var (
+	numberEncoders = map[string]string{
+		utils.EncodingChar: "ascii",
+		utils.EncodingBcd:  "bcd",
+		utils.EncodingRBcd: "rbcd",
+	}
+	characterEncoders = map[string]string{
+		utils.EncodingChar:   "ascii",
+		utils.EncodingAscii:  "ascii",
+		utils.EncodingEbcdic: "ebcdic",
+	}
+)
+
+// variable length field types by number of digits of the length head
+var (
+	numberVarTypes    = map[int]string{2: "llnumeric", 3: "lllnumeric"}
+	characterVarTypes = map[int]string{2: "llvar", 3: "lllvar", 8: "l8var"}
+)
+
+// field is a struct field of a generated template
+type field struct {
+	Index       int
+	Type        string
+	Encode      string
+	Length      int
+	Mandatory   bool
+	Description string
+}
+
+// Generate returns formatted Go source of package pkg with one template
+// struct per message type of spec. Mandatory fields are string values,
+// optional fields are string pointers.
+func Generate(spec *utils.Specification, pkg string) ([]byte, error) {
+	if spec.MessageTypes == nil || len(*spec.MessageTypes) == 0 {
+		return nil, ErrNoMessageTypes
+	}
+	if spec.Elements == nil {
+		return nil, errors.New(utils.ErrNonExistSpecification)
+	}
+	encoding := spec.Encoding
+	if encoding == nil {
+		encoding = utils.DefaultMessageEncoding
+	}
+
+	mtis := make([]string, 0, len(*spec.MessageTypes))
+	for mti := range *spec.MessageTypes {
+		mtis = append(mtis, mti)
+	}
+	sort.Strings(mtis)
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "// Code generated by iso8583 gen. DO NOT EDIT.\n\npackage %s\n", pkg)
+	for _, mti := range mtis {
+		fields, err := messageFields((*spec.MessageTypes)[mti], *spec.Elements, encoding)
+		if err != nil {
+			return nil, fmt.Errorf("MTI %s: %w", mti, err)
+		}
+		fmt.Fprintf(buf, "\n// Message%s is the template of MTI %s\ntype Message%s struct {\n", mti, mti, mti)
+		for _, f := range fields {
+			goType := "*string"
+			if f.Mandatory {
+				goType = "string"
+			}
+			if f.Description != "" {
+				fmt.Fprintf(buf, "// %s\n", f.Description)
+			}
+			fmt.Fprintf(buf, "F%d %s `field:\"%d\" type:\"%s\" encode:\"%s\" length:\"%d\"`\n",
+				f.Index, goType, f.Index, f.Type, f.Encode, f.Length)
+		}
+		buf.WriteString("}\n")
+	}
+	return format.Source(buf.Bytes())
+}
+
+// messageFields returns fields of message type t ordered by index. Fields
+// 1 and 65 are skipped, they are the bitmaps of iso8583 messages.
+func messageFields(t utils.MessageType, elements utils.Attributes, encoding *utils.EncodingDefinition) ([]field, error) {
+	mandatory, optional, err := t.Fields()
+	if err != nil {
+		return nil, err
+	}
+	// mandatory by index of present fields
+	present := make(map[int]bool)
+	for _, index := range optional {
+		present[index] = false
+	}
+	for _, index := range mandatory {
+		present[index] = true
+	}
+	indexes := make([]int, 0, len(present))
+	for index := range present {
+		if index != 1 && index != 65 {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+
+	fields := make([]field, 0, len(indexes))
+	for _, index := range indexes {
+		attr, err := elements.Get(index)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %s", index, err)
+		}
+		f, err := elementField(index, attr, encoding)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", index, err)
+		}
+		f.Mandatory = present[index]
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// elementField maps the data element of the specification to the field
+// type of iso8583 package with the same wire format
+func elementField(index int, attr *utils.Attribute, encoding *utils.EncodingDefinition) (field, error) {
+	f := field{Index: index, Description: attr.Description}
+	et, err := attr.Parse()
+	if err != nil {
+		return f, err
+	}
+	et.SetEncoding(encoding)
+	f.Length = et.Length
+
+	var valueEnc string
+	var varTypes map[int]string
+	switch utils.AvailableTypeCategory[et.Type] {
+	case utils.EncodingCatNumber:
+		valueEnc = numberEncoders[et.Encoding]
+		varTypes = numberVarTypes
+		f.Type = "numeric"
+	case utils.EncodingCatCharacter:
+		valueEnc = characterEncoders[et.Encoding]
+		varTypes = characterVarTypes
+		f.Type = "alphanumeric"
+	case utils.EncodingCatBinary:
+		// opaque bytes are sent as they are, length is given in bits
+		if et.Fixed && et.Encoding == utils.EncodingBinary {
+			f.Type, f.Encode, f.Length = "binary", "ascii", (et.Length+7)/8
+			return f, nil
+		}
+		// bits are sent as characters, or as hex digits
+		if !et.Fixed {
+			return f, errors.New("variable length binary isn't supported")
+		}
+		switch et.Encoding {
+		case utils.EncodingChar:
+			valueEnc = "ascii"
+		case utils.EncodingHex:
+			valueEnc = "ascii"
+			f.Length = et.Length / 4
+		}
+		f.Type = "alphanumeric"
+	default:
+		return f, errors.New(utils.ErrInvalidElementType)
+	}
+	if valueEnc == "" {
+		return f, fmt.Errorf("%s %s", utils.ErrNonAvailableEncoding, et.Encoding)
+	}
+	f.Encode = valueEnc
+	if et.Fixed {
+		return f, nil
+	}
+
+	digits := len(strconv.Itoa(et.Length))
+	if et.LengthSize > 0 {
+		digits = et.LengthSize
+	}
+	typ, ok := varTypes[digits]
+	if !ok {
+		return f, fmt.Errorf("%s: %d digits", utils.ErrInvalidLengthHead, digits)
+	}
+	lenEnc, ok := numberEncoders[et.LengthEncoding]
+	if !ok {
+		return f, fmt.Errorf("%s %s", utils.ErrInvalidLengthEncoder, et.LengthEncoding)
+	}
+	f.Type = typ
+	f.Encode = lenEnc + "," + valueEnc
+	return f, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package gen
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/moov-io/iso8583/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	source, err := Generate(&utils.ISO8583DataElementsVer1987, "messages")
+	assert.Nil(t, err)
+	code := string(source)
+	assert.Contains(t, code, "// Code generated by iso8583 gen. DO NOT EDIT.\n\npackage messages\n")
+	assert.Contains(t, code, "// Message0100 is the template of MTI 0100\ntype Message0100 struct {\n")
+	assert.Contains(t, code, "\t// Primary account number (PAN)\n\tF2 string `field:\"2\" type:\"llnumeric\" encode:\"ascii,ascii\" length:\"19\"`\n")
+	assert.Contains(t, code, "\t// Track 2 data\n\tF35 *string `field:\"35\" type:\"llvar\" encode:\"ascii,ebcdic\" length:\"37\"`\n")
+	assert.Contains(t, code, "\tF39 string `field:\"39\" type:\"alphanumeric\" encode:\"ascii\" length:\"2\"`\n")
+	assert.Contains(t, code, "type Message0450 struct {\n")
+	assert.NotContains(t, code, "F1 ")
+	assert.NotContains(t, code, "F65 ")
+}
+
+func TestGenerateEncodings(t *testing.T) {
+	spec := &utils.Specification{
+		Elements: &utils.Attributes{
+			2:  {Describe: "n..19", Description: "PAN"},
+			4:  {Describe: "n 12", Description: "Amount"},
+			52: {Describe: "b 64", Description: "PIN"},
+			60: {Describe: "ans...999"},
+		},
+		Encoding: &utils.EncodingDefinition{
+			LengthEnc:    utils.EncodingBcd,
+			NumberEnc:    utils.EncodingRBcd,
+			CharacterEnc: utils.EncodingEbcdic,
+			BinaryEnc:    utils.EncodingHex,
+		},
+		MessageTypes: &utils.MessageTypes{
+			"0200": {
+				MandatoryHexMask: "50000000000000000000000000000000",
+				OptionalHexMask:  "00000000000010100000000000000000",
+			},
+		},
+	}
+	source, err := Generate(spec, "pos")
+	assert.Nil(t, err)
+	code := string(source)
+	assert.Contains(t, code, "\tF2 string `field:\"2\" type:\"llnumeric\" encode:\"bcd,rbcd\" length:\"19\"`\n")
+	assert.Contains(t, code, "\tF4 string `field:\"4\" type:\"numeric\" encode:\"rbcd\" length:\"12\"`\n")
+	assert.Contains(t, code, "\t// PIN\n\tF52 *string `field:\"52\" type:\"alphanumeric\" encode:\"ascii\" length:\"16\"`\n")
+	assert.Contains(t, code, "\tF60 *string `field:\"60\" type:\"lllvar\" encode:\"bcd,ebcdic\" length:\"999\"`\n")
+
+	// opaque bytes
+	spec.Encoding.BinaryEnc = utils.EncodingBinary
+	(*spec.Elements)[52] = utils.Attribute{Describe: "b 64", Description: "PIN"}
+	source, err = Generate(spec, "pos")
+	assert.Nil(t, err)
+	assert.Contains(t, string(source), "\tF52 *string `field:\"52\" type:\"binary\" encode:\"ascii\" length:\"8\"`\n")
+
+	spec.Encoding.BinaryEnc = utils.EncodingHex
+	(*spec.Elements)[52] = utils.Attribute{Describe: "b..64"}
+	_, err = Generate(spec, "pos")
+	assert.EqualError(t, err, "MTI 0200: field 52: variable length binary isn't supported")
+
+	(*spec.MessageTypes)["0200"] = utils.MessageType{MandatoryHexMask: "01"}
+	_, err = Generate(spec, "pos")
+	assert.EqualError(t, err, "MTI 0200: field 8: don't exist specification")
+
+	_, err = Generate(&utils.Specification{Elements: spec.Elements}, "pos")
+	assert.True(t, errors.Is(err, ErrNoMessageTypes))
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+
+	"github.com/moov-io/iso8583/pkg/utils"
+)
+
+// names of rules of constraints
+const (
+	RuleValues    = "values"
+	RuleMin       = "min"
+	RuleMax       = "max"
+	RuleMinLength = "min_length"
+	RuleRegex     = "regex"
+	RuleLuhn      = "luhn"
+)
+
+// private functions ...
+
+// validateConstraints check value with constraints of the specification
+func (e *Element) validateConstraints() error {
+	c := e.Constraints
+	value := e.String()
+
+	if len(c.Values) > 0 && !containsString(c.Values, value) {
+		return constraintError(RuleValues)
+	}
+	if c.Min != nil || c.Max != nil {
+		number, ok := new(big.Int).SetString(value, 10)
+		if c.Min != nil && (!ok || number.Cmp(big.NewInt(*c.Min)) < 0) {
+			return constraintError(RuleMin)
+		}
+		if c.Max != nil && (!ok || number.Cmp(big.NewInt(*c.Max)) > 0) {
+			return constraintError(RuleMax)
+		}
+	}
+	if len(value) < c.MinLength {
+		return constraintError(RuleMinLength)
+	}
+	if c.Regex != "" {
+		// regular expression must match the whole value
+		regex, err := regexp.Compile(`^(?:` + c.Regex + `)$`)
+		if err != nil || !regex.MatchString(value) {
+			return constraintError(RuleRegex)
+		}
+	}
+	if c.Luhn && !validLuhn(value) {
+		return constraintError(RuleLuhn)
+	}
+	return nil
+}
+
+func constraintError(rule string) error {
+	return ruleError(rule, fmt.Errorf("%s: %s", utils.ErrViolatedConstraint, rule))
+}
+
+// validLuhn check the last digit of number with Luhn algorithm
+func validLuhn(number string) bool {
+	if len(number) < 2 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(number); i++ {
+		digit := number[len(number)-1-i]
+		if digit < '0' || digit > '9' {
+			return false
+		}
+		d := int(digit - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/moov-io/iso8583/pkg/utils"
+)
+
+// SetTag set value of dataset tag, it's checked by the attribute of known
+// tag
+func (e *Element) SetTag(tag string, value string) error {
+	if !e.isDataset() {
+		return errors.New(utils.ErrNonExistSpecification)
+	}
+	if err := e.validateTag(tag, value); err != nil {
+		return err
+	}
+	if e.Tags == nil {
+		e.Tags = make(map[string]string)
+	}
+	e.Tags[tag] = value
+	return nil
+}
+
+// private functions ...
+func (e *Element) isDataset() bool {
+	return e.Dataset != nil
+}
+
+// tagKeys return tags in order of packing
+func (e *Element) tagKeys() []string {
+	var keys []string
+	for k := range e.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateTag check width of tag and value of known tag, unknown tags are
+// rejected when the dataset requires it
+func (e *Element) validateTag(tag string, value string) error {
+	if len(tag) != e.Dataset.TagWidth {
+		return ruleError(RuleDataset, errors.New(utils.ErrInvalidDataset))
+	}
+	attr, known := e.Dataset.Tags[tag]
+	if !known {
+		if e.Dataset.Unknown == utils.UnknownTagsReject {
+			return ruleError(RuleDataset, fmt.Errorf("%s: %s", utils.ErrUnknownTag, tag))
+		}
+		return nil
+	}
+
+	elm, err := newElement(&attr, e.encodingDefinition())
+	if err != nil {
+		return ruleError(RuleDataset, err)
+	}
+	if elm.Fixed && len(value) != elm.Length {
+		return ruleError(RuleLength, errors.New(utils.ErrInvalidElementLength))
+	}
+	if len(value) > elm.Length {
+		return ruleError(RuleLength, fmt.Errorf(utils.ErrValueTooLong, elm.Type, elm.Length, len(value)))
+	}
+	if err := elm.setText([]byte(value)); err != nil {
+		return ruleError(RuleType, err)
+	}
+	return elm.Validate()
+}
+
+func (e *Element) validateDataset() error {
+	if err := e.validateWithEncoding(); err != nil {
+		return ruleError(RuleEncoding, err)
+	}
+	for _, tag := range e.tagKeys() {
+		if err := e.validateTag(tag, e.Tags[tag]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// datasetText create element of dataset characters, they have the encoding
+// of the dataset element
+func (e *Element) datasetText(length int) *Element {
+	return &Element{
+		Type:     utils.ElementTypeAlphaNumericSpecial,
+		Length:   length,
+		Fixed:    true,
+		Encoding: e.Encoding,
+	}
+}
+
+// datasetLength create element of length heads of dataset, its length is
+// the maximum length of values
+func (e *Element) datasetLength() *Element {
+	encoding := e.Dataset.LengthEncoding
+	if encoding == "" {
+		encoding = e.encodingDefinition().LengthEnc
+	}
+	base := 10.0
+	switch encoding {
+	case utils.EncodingHex:
+		base = 16
+	case utils.EncodingBinary:
+		base = 256
+	}
+	return &Element{
+		Length:         int(math.Pow(base, float64(e.Dataset.LengthWidth))) - 1,
+		LengthSize:     e.Dataset.LengthWidth,
+		LengthEncoding: encoding,
+	}
+}
+
+// packDataset encode tags one after another, ordered by tag
+func (e *Element) packDataset() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, tag := range e.tagKeys() {
+		if len(tag) != e.Dataset.TagWidth {
+			return nil, errors.New(utils.ErrInvalidDataset)
+		}
+		tagElm := e.datasetText(len(tag))
+		tagElm.Value = []byte(tag)
+		encoded, err := tagElm.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encoded)
+
+		value := e.datasetText(len(e.Tags[tag]))
+		value.Value = []byte(e.Tags[tag])
+		if encoded, err = value.Bytes(); err != nil {
+			return nil, err
+		}
+		head, err := e.datasetLength().lengthEncoding(encoded)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(head)
+		buf.Write(encoded)
+	}
+	return buf.Bytes(), nil
+}
+
+// unpackDataset decode tags from content of the element
+func (e *Element) unpackDataset(content []byte) error {
+	tags := make(map[string]string)
+	for start := 0; start < len(content); {
+		tagElm := e.datasetText(e.Dataset.TagWidth)
+		read, err := tagElm.Load(content[start:])
+		if err != nil {
+			return err
+		}
+		start += read
+		tag := string(tagElm.Value)
+		if _, exist := tags[tag]; exist {
+			return errors.New(utils.ErrBadElementData)
+		}
+		if _, known := e.Dataset.Tags[tag]; !known && e.Dataset.Unknown == utils.UnknownTagsReject {
+			return fmt.Errorf("%s: %s", utils.ErrUnknownTag, tag)
+		}
+
+		length := e.datasetLength()
+		if read, err = length.lengthDecoding(content[start:]); err != nil {
+			return err
+		}
+		start += read
+		value := e.datasetText(length.DataLength)
+		if read, err = value.Load(content[start:]); err != nil {
+			return err
+		}
+		start += read
+		tags[tag] = string(value.Value)
+	}
+	e.Tags = tags
+	if !e.Fixed {
+		e.DataLength = len(content)
+	}
+	return nil
+}
+
+// datasetFromJSON decode tags from json object, which was kept until the
+// element is typed
+func (e *Element) datasetFromJSON() error {
+	var tags map[string]string
+	if err := json.Unmarshal(e.rawJSON, &tags); err != nil {
+		return err
+	}
+	e.rawJSON = nil
+	e.Tags = tags
+	return nil
+}
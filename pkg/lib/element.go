@@ -5,6 +5,10 @@
 package lib
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
@@ -25,39 +29,94 @@ type Element struct {
 	Fixed          bool   `xml:"-" json:"-"`
 	LengthEncoding string `xml:"-" json:"-"`
 	DataLength     int    `xml:"-" json:"-"`
+	LengthSize     int    `xml:"-" json:"-"` // width of length head, 0 when it's given by Length
+	Padding        string `xml:"-" json:"-"`
+	Justify        string `xml:"-" json:"-"`
+	TextEncoding   string `xml:"-" json:"-"` // json and xml encoding of opaque binary data
 	Value          []byte `xml:"-" json:"-"` // raw data without any encoding, equal size of value and length (data length) of element
+
+	// composite element has subfields instead of value
+	SubfieldAttributes utils.Attributes `xml:"-" json:"-"`
+	SubfieldBitmap     int              `xml:"-" json:"-"`
+	Subfields          map[int]*Element `xml:"-" json:"-"`
+
+	// value of TLV element is a list of BER-TLV data objects
+	TLV bool `xml:"-" json:"-"`
+
+	// value of dataset element is a list of private tag-length-value subfields
+	Dataset *utils.Dataset    `xml:"-" json:"-"`
+	Tags    map[string]string `xml:"-" json:"-"`
+
+	// constraints of the value declared by the specification
+	Constraints utils.Constraints `xml:"-" json:"-"`
+
+	definition *utils.EncodingDefinition // encodings of subfields
+	rawJSON    json.RawMessage           // subfields, data objects or tags before the element is typed
 }
 
-// Validate check validation of field
+// Validate check validation of field, the error is RuleError with name of
+// the violated rule
 func (e *Element) Validate() error {
+	if e.isComposite() {
+		return e.validateSubfields()
+	}
+	if e.isDataset() {
+		return e.validateDataset()
+	}
+
 	// Checking available encoding
 	err := e.validateWithEncoding()
 	if err != nil {
-		return err
+		return ruleError(RuleEncoding, err)
+	}
+
+	// Length
+	if err := e.validateLength(); err != nil {
+		return ruleError(RuleLength, err)
+	}
+
+	// BER-TLV data objects
+	if e.TLV {
+		if err := e.validateTLV(); err != nil {
+			return err
+		}
 	}
 
 	// Regex
 	err = e.validateWithRegex()
 	if err != nil {
-		return err
+		return ruleError(RuleType, err)
 	}
 
 	// Date Format
 	err = e.validateWithFormat()
 	if err != nil {
-		return err
+		return ruleError(RuleFormat, err)
 	}
 
-	return nil
+	// Constraints of the specification
+	return e.validateConstraints()
 }
 
-// String field to string
+// String field to string, opaque binary data is hex or base64 text,
+// composite element is its packed subfields and dataset is its packed tags
 func (e *Element) String() string {
+	if e.isComposite() || e.isDataset() {
+		content, _ := e.packContent()
+		return string(content)
+	}
+	if e.isOpaque() {
+		return e.binaryText()
+	}
 	return fmt.Sprintf("%s", e.Value)
 }
 
 // Bytes encode field to bytes
 func (e *Element) Bytes() ([]byte, error) {
+	if e.isComposite() || e.isDataset() {
+		return e.compositeEncoding()
+	}
+
 	dataLen := e.Length
 	if !e.Fixed {
 		dataLen = e.DataLength
@@ -80,6 +139,9 @@ func (e *Element) Bytes() ([]byte, error) {
 
 // Load decode field from bytes
 func (e *Element) Load(raw []byte) (int, error) {
+	if e.isComposite() || e.isDataset() {
+		return e.compositeDecoding(raw)
+	}
 	switch utils.AvailableTypeCategory[e.Type] {
 	case utils.EncodingCatCharacter:
 		return e.characterDecoding(raw)
@@ -93,21 +155,38 @@ func (e *Element) Load(raw []byte) (int, error) {
 
 // Customize unmarshal of json
 func (e *Element) UnmarshalJSON(b []byte) error {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		// subfields, data objects and tags are decoded when the element is typed
+		e.rawJSON = append(json.RawMessage(nil), b...)
+		if e.isComposite() || e.TLV || e.isDataset() {
+			return e.decodeRawJSON()
+		}
+		return nil
+	}
+
 	var value string
 	err := json.Unmarshal(b, &value)
 	if err != nil {
 		return err
 	}
-	e.Value = make([]byte, len(value))
-	copy(e.Value, value)
-	e.DataLength = len(e.Value)
-	e.extendBinaryData()
-	return nil
+	return e.setText([]byte(value))
 }
 
 // Customize marshal of json
 func (e *Element) MarshalJSON() ([]byte, error) {
-	return json.Marshal(fmt.Sprintf("%s", e.Value))
+	if e.isComposite() {
+		return json.Marshal(e.Subfields)
+	}
+	if e.isDataset() {
+		return json.Marshal(e.Tags)
+	}
+	if e.TLV {
+		// malformed data objects are kept as text of binary data
+		if tlvs, err := e.TLVs(); err == nil {
+			return json.Marshal(tlvs)
+		}
+	}
+	return json.Marshal(e.String())
 }
 
 // Customize unmarshal of xml
@@ -116,10 +195,7 @@ func (e *Element) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	if err := d.DecodeElement(&value, &start); err != nil {
 		return err
 	}
-	e.Value = make([]byte, len(value))
-	copy(e.Value, value)
-	e.extendBinaryData()
-	return nil
+	return e.setText([]byte(value))
 }
 
 // Customize marshal of xml
@@ -131,8 +207,7 @@ func (e *Element) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error
 		}
 		return encoder.EncodeElement(ret, start)
 	}
-	s := fmt.Sprintf("%s", e.Value)
-	return encoder.EncodeElement(s, start)
+	return encoder.EncodeElement(e.String(), start)
 }
 
 // private functions ...
@@ -154,7 +229,10 @@ func (e *Element) characterEncoding() ([]byte, error) {
 		return nil, err
 	}
 
-	paddingValue := e.characterWithPadding(encodingValue)
+	paddingValue, err := e.characterWithPadding(encodingValue)
+	if err != nil {
+		return nil, err
+	}
 	if e.Fixed {
 		return paddingValue, nil
 	}
@@ -200,6 +278,10 @@ func (e *Element) numberEncoding() ([]byte, error) {
 func (e *Element) binaryEncoding() ([]byte, error) {
 	var value []byte
 
+	if e.isOpaque() {
+		return e.opaqueEncoding()
+	}
+
 	if e.Length != len(e.Value) {
 		return nil, errors.New(utils.ErrBadBinary)
 	}
@@ -214,6 +296,14 @@ func (e *Element) binaryEncoding() ([]byte, error) {
 		}
 		hexStr := fmt.Sprintf("%0"+strconv.Itoa(e.Length/4)+"s", strconv.FormatUint(bitNum, 16))
 		value = []byte(strings.ToUpper(hexStr))
+	} else if e.Encoding == utils.EncodingBinary {
+		// bits of bitmap are packed in bytes
+		value = make([]byte, e.Length/8)
+		for i, bit := range e.Value {
+			if bit == '1' {
+				value[i/8] |= 0x80 >> uint(i%8)
+			}
+		}
 	} else {
 		return nil, errors.New(utils.ErrInvalidEncoder)
 	}
@@ -221,6 +311,28 @@ func (e *Element) binaryEncoding() ([]byte, error) {
 	return value, nil
 }
 
+func (e *Element) opaqueEncoding() ([]byte, error) {
+	if e.Fixed && len(e.Value) != e.Length {
+		return nil, errors.New(utils.ErrBadBinary)
+	}
+
+	value := e.Value
+	if e.Encoding == utils.EncodingHexBinary {
+		value = []byte(strings.ToUpper(hex.EncodeToString(e.Value)))
+	}
+	if e.Fixed {
+		return value, nil
+	}
+
+	// length head is the number of bytes
+	lenEncode, err := e.lengthEncoding(e.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(lenEncode, value...), nil
+}
+
 func (e *Element) characterDecoding(raw []byte) (int, error) {
 	read, err := e.lengthDecoding(raw)
 	if err != nil {
@@ -321,6 +433,10 @@ func (e *Element) binaryDecoding(raw []byte) (int, error) {
 	var contentLen int
 	var read int
 
+	if e.isOpaque() {
+		return e.opaqueDecoding(raw)
+	}
+
 	contentLen = e.Length
 	if e.Encoding == utils.EncodingChar {
 		if len(raw) < read+contentLen {
@@ -350,6 +466,14 @@ func (e *Element) binaryDecoding(raw []byte) (int, error) {
 		read += contentLen / 4
 
 		return read, nil
+	} else if e.Encoding == utils.EncodingBinary {
+		if len(raw) < read+contentLen/8 {
+			return 0, errors.New(utils.ErrBadElementData)
+		}
+		for _, b := range raw[read : read+contentLen/8] {
+			value = append(value, fmt.Sprintf("%08b", b)...)
+		}
+		read += contentLen / 8
 	} else {
 		return 0, errors.New(utils.ErrInvalidEncoder)
 	}
@@ -360,18 +484,60 @@ func (e *Element) binaryDecoding(raw []byte) (int, error) {
 	return read, nil
 }
 
+func (e *Element) opaqueDecoding(raw []byte) (int, error) {
+	read, err := e.lengthDecoding(raw)
+	if err != nil {
+		return 0, err
+	}
+
+	contentLen := e.Length
+	if !e.Fixed {
+		contentLen = e.DataLength
+	}
+	size := contentLen
+	if e.Encoding == utils.EncodingHexBinary {
+		size *= 2
+	}
+	if len(raw) < read+size {
+		return 0, errors.New(utils.ErrBadElementData)
+	}
+
+	value := raw[read : read+size]
+	if e.Encoding == utils.EncodingHexBinary {
+		value, err = hex.DecodeString(string(value))
+		if err != nil {
+			return 0, errors.New(utils.ErrBadElementData)
+		}
+	}
+
+	e.Value = make([]byte, len(value))
+	copy(e.Value, value)
+	read += size
+
+	return read, nil
+}
+
 func (e *Element) lengthEncoding(value []byte) ([]byte, error) {
 	var encode []byte
-	lenStr := strconv.Itoa(e.Length)
-	formatStr := "%0" + strconv.Itoa(len(lenStr)) + "d"
+	size := e.lengthSize()
+	formatStr := "%0" + strconv.Itoa(size) + "d"
 	contentLen := []byte(fmt.Sprintf(formatStr, len(value)))
+	if e.LengthSize > 0 && e.LengthEncoding != utils.EncodingBinary {
+		// length head stated by the specification may be too narrow
+		digits := len(contentLen)
+		if e.LengthEncoding == utils.EncodingHex {
+			digits = len(fmt.Sprintf("%x", len(value)))
+		}
+		if digits > size {
+			return nil, errors.New(utils.ErrInvalidElementLength)
+		}
+	}
 
 	switch e.LengthEncoding {
 	case utils.EncodingChar:
 		encode = contentLen
 	case utils.EncodingHex:
-		lenStr = strconv.Itoa(len(fmt.Sprintf("%x", e.Length)))
-		formatStr = "%0" + strconv.Itoa(len(lenStr)) + "x"
+		formatStr = "%0" + strconv.Itoa(size) + "x"
 		encode = []byte(strings.ToUpper(fmt.Sprintf(formatStr, len(value))))
 	case utils.EncodingRBcd:
 		// contentLen is number only
@@ -379,6 +545,13 @@ func (e *Element) lengthEncoding(value []byte) ([]byte, error) {
 	case utils.EncodingBcd:
 		// contentLen is number only
 		encode, _ = utils.Bcd(contentLen)
+	case utils.EncodingBinary:
+		encode = make([]byte, 4)
+		binary.BigEndian.PutUint32(encode, uint32(len(value)))
+		if !validBinaryLengthSize(size) || uint64(len(value)) >= 1<<(8*uint(size)) {
+			return nil, errors.New(utils.ErrInvalidElementLength)
+		}
+		encode = encode[4-size:]
 	default:
 		return nil, errors.New(utils.ErrInvalidLengthEncoder)
 	}
@@ -392,7 +565,7 @@ func (e *Element) lengthDecoding(raw []byte) (int, error) {
 	var err error
 
 	if !e.Fixed {
-		lenSize := len(strconv.Itoa(e.Length))
+		lenSize := e.lengthSize()
 		bcdSize := lenSize / 2
 		if lenSize%2 != 0 {
 			bcdSize++
@@ -410,8 +583,7 @@ func (e *Element) lengthDecoding(raw []byte) (int, error) {
 			}
 			read = lenSize
 		case utils.EncodingHex:
-			lenStr := strconv.Itoa(len(fmt.Sprintf("%x", e.Length)))
-			read = len(lenStr)
+			read = lenSize
 			n, err := strconv.ParseInt(string(raw[:read]), 16, 32)
 			if err != nil {
 				return 0, errors.New(utils.ErrParseLengthFailed + ": " + string(raw[:read]))
@@ -433,6 +605,19 @@ func (e *Element) lengthDecoding(raw []byte) (int, error) {
 			// lenVal is decimal numbers only
 			contentLen, _ = strconv.Atoi(string(lenVal))
 			read = bcdSize
+		case utils.EncodingBinary:
+			if !validBinaryLengthSize(lenSize) {
+				return 0, errors.New(utils.ErrInvalidLengthHead)
+			}
+			var n uint64
+			for _, b := range raw[:lenSize] {
+				n = n<<8 | uint64(b)
+			}
+			if n > uint64(e.Length) {
+				return 0, errors.New(utils.ErrInvalidElementLength)
+			}
+			contentLen = int(n)
+			read = lenSize
 		default:
 			return 0, errors.New(utils.ErrInvalidLengthEncoder)
 		}
@@ -445,9 +630,51 @@ func (e *Element) lengthDecoding(raw []byte) (int, error) {
 	return read, nil
 }
 
+// lengthSize return width of length head, digits of decimal and hex
+// encodings or bytes of BINARY encoding. The width is given by the maximum
+// length, unless the specification states it.
+func (e *Element) lengthSize() int {
+	if e.LengthSize > 0 {
+		return e.LengthSize
+	}
+	switch e.LengthEncoding {
+	case utils.EncodingHex:
+		return len(strconv.Itoa(len(fmt.Sprintf("%x", e.Length))))
+	case utils.EncodingBinary:
+		if e.Length <= 0xFF {
+			return 1
+		} else if e.Length <= 0xFFFF {
+			return 2
+		}
+		return 4
+	}
+	return len(strconv.Itoa(e.Length))
+}
+
+// validateLength check length of value with the rules of Bytes, fixed
+// binary data must fill the element and length head must hold the length
+func (e *Element) validateLength() error {
+	if len(e.Value) > e.Length {
+		return fmt.Errorf(utils.ErrValueTooLong, e.Type, e.Length, len(e.Value))
+	}
+	binary := utils.AvailableTypeCategory[e.Type] == utils.EncodingCatBinary
+	if binary && (e.Fixed || !e.isOpaque()) && len(e.Value) != e.Length {
+		return errors.New(utils.ErrBadBinary)
+	}
+	if !e.Fixed {
+		_, err := e.lengthEncoding(e.Value)
+		return err
+	}
+	return nil
+}
+
+func validBinaryLengthSize(size int) bool {
+	return size == 1 || size == 2 || size == 4
+}
+
 func (e *Element) extendBinaryData() {
 	cat := utils.AvailableTypeCategory[e.Type]
-	if cat == utils.EncodingCatBinary && (len(e.Value) < e.Length) {
+	if cat == utils.EncodingCatBinary && !e.isOpaque() && (len(e.Value) < e.Length) {
 		newData := fmt.Sprintf("%-"+strconv.Itoa(e.Length)+"s", string(e.Value))
 		newData = strings.ReplaceAll(newData, " ", "0")
 		e.Value = make([]byte, e.Length)
@@ -455,6 +682,45 @@ func (e *Element) extendBinaryData() {
 	}
 }
 
+// newElement create element without value, typed by attribute of the
+// specification
+func newElement(attr *utils.Attribute, encoding *utils.EncodingDefinition) (*Element, error) {
+	elm := &Element{}
+	if err := elm.setAttribute(attr, encoding); err != nil {
+		return nil, err
+	}
+	return elm, nil
+}
+
+// newDataElement create data element of message, binary bitmap elements
+// aren't opaque binary data
+func newDataElement(index int, attr *utils.Attribute, encoding *utils.EncodingDefinition) (*Element, error) {
+	elm, err := newElement(attr, encoding)
+	if err != nil {
+		return nil, err
+	}
+	if isBitmapIndex(index) {
+		if err := elm.typeAsBitmap(attr); err != nil {
+			return nil, err
+		}
+	}
+	return elm, nil
+}
+
+func (e *Element) setAttribute(attr *utils.Attribute, encoding *utils.EncodingDefinition) error {
+	_type, err := attr.Parse()
+	if err != nil {
+		return err
+	}
+	_type.SetEncoding(encoding)
+	if err := _type.Validate(); err != nil {
+		return err
+	}
+	e.setType(_type)
+	e.definition = encoding
+	return nil
+}
+
 func (e *Element) setType(_type *utils.ElementType) {
 	e.Type = _type.Type
 	e.Length = _type.Length
@@ -462,16 +728,98 @@ func (e *Element) setType(_type *utils.ElementType) {
 	e.Encoding = _type.Encoding
 	e.Fixed = _type.Fixed
 	e.LengthEncoding = _type.LengthEncoding
+	e.LengthSize = _type.LengthSize
+	e.Padding = _type.Padding
+	e.Justify = _type.Justify
+	e.SubfieldAttributes = _type.Subfields
+	e.SubfieldBitmap = _type.SubfieldBitmap
+	e.TextEncoding = _type.TextEncoding
+	e.TLV = _type.TLV
+	e.Dataset = _type.Dataset
+	e.Constraints = _type.Constraints
+	if e.Fixed && e.isOpaque() {
+		// length of fixed binary data is given in bits
+		e.Length = (e.Length + 7) / 8
+	}
 	e.extendBinaryData()
 }
 
+// typeAsBitmap type opaque binary element as bitmap, bitmap elements keep
+// bits of the bitmap instead of bytes
+func (e *Element) typeAsBitmap(attr *utils.Attribute) error {
+	if !e.isOpaque() {
+		return nil
+	}
+	_type, err := attr.Parse()
+	if err != nil {
+		return err
+	}
+	_type.Type = utils.ElementTypeBitmap
+	_type.SetEncoding(e.definition)
+	e.setType(_type)
+	return nil
+}
+
+// decodeRawJSON decode json kept until the element is typed, json array
+// of data objects for TLV element, json object of tags for dataset and json
+// object of subfields otherwise
+func (e *Element) decodeRawJSON() error {
+	if e.TLV {
+		return e.tlvsFromJSON()
+	}
+	if e.isDataset() {
+		return e.datasetFromJSON()
+	}
+	return e.subfieldsFromJSON()
+}
+
+// isOpaque return whether the element is binary data of bytes, instead of
+// bit characters
+func (e *Element) isOpaque() bool {
+	return utils.IsOpaqueBinary(e.Type, e.Encoding)
+}
+
+// setText set value from text of json and xml, opaque binary data is
+// decoded from hex or base64, composite element from packed subfields and
+// dataset from packed tags
+func (e *Element) setText(text []byte) error {
+	if e.isComposite() || e.isDataset() {
+		return e.unpackContent(text)
+	}
+	value := text
+	if e.isOpaque() {
+		var err error
+		if e.TextEncoding == utils.EncodingBase64 {
+			value, err = base64.StdEncoding.DecodeString(string(text))
+		} else {
+			value, err = hex.DecodeString(string(text))
+		}
+		if err != nil {
+			return errors.New(utils.ErrBadElementData)
+		}
+	}
+	e.Value = make([]byte, len(value))
+	copy(e.Value, value)
+	e.DataLength = len(e.Value)
+	e.extendBinaryData()
+	return nil
+}
+
+// binaryText return text of opaque binary data for json and xml
+func (e *Element) binaryText() string {
+	if e.TextEncoding == utils.EncodingBase64 {
+		return base64.StdEncoding.EncodeToString(e.Value)
+	}
+	return strings.ToUpper(hex.EncodeToString(e.Value))
+}
+
 func (e *Element) numberWithPadding(buf []byte, isNumeric bool) []byte {
 	var length = e.Length
 	if !e.Fixed {
 		return buf
 	}
 	if e.Encoding == utils.EncodingChar {
-		return []byte(fmt.Sprintf("%0"+strconv.Itoa(length)+"s", buf))
+		return e.withPadding(buf, '0', utils.JustifyRight)
 	}
 
 	bcdSize := length / 2
@@ -500,13 +848,39 @@ func (e *Element) numberWithPadding(buf []byte, isNumeric bool) []byte {
 	return value
 }
 
-func (e *Element) characterWithPadding(buf []byte) []byte {
-	var length = e.Length
+func (e *Element) characterWithPadding(buf []byte) ([]byte, error) {
 	if !e.Fixed {
+		return buf, nil
+	}
+	padding := byte(' ')
+	if e.Padding != "" && e.Encoding == utils.EncodingEbcdic {
+		// padding character of the attribute is encoded like the value
+		encoded, err := ebcdic.Encode(e.Padding, ebcdic.EBCDIC037)
+		if err != nil {
+			return nil, err
+		}
+		padding = encoded[0]
+	}
+	return e.withPadding(buf, padding, utils.JustifyLeft), nil
+}
+
+// withPadding fill fixed element up to its length, padding character and
+// justification of the attribute take precedence over the given defaults
+func (e *Element) withPadding(buf []byte, padding byte, justify string) []byte {
+	if len(buf) >= e.Length {
 		return buf
 	}
-	fmtStr := "%-" + strconv.Itoa(length) + "s"
-	return []byte(fmt.Sprintf(fmtStr, buf))
+	if e.Padding != "" && e.Encoding != utils.EncodingEbcdic {
+		padding = e.Padding[0]
+	}
+	if e.Justify != "" {
+		justify = e.Justify
+	}
+	pad := bytes.Repeat([]byte{padding}, e.Length-len(buf))
+	if justify == utils.JustifyRight {
+		return append(pad, buf...)
+	}
+	return append(append([]byte(nil), buf...), pad...)
 }
 
 func (e *Element) validateWithRegex() error {
@@ -525,7 +899,7 @@ func (e *Element) validateWithRegex() error {
 	case utils.ElementTypeIndicate:
 		match = utils.RegexIndicate(string(e.Value))
 	case utils.ElementTypeBinary, utils.ElementTypeBitmap:
-		match = utils.RegexBinary(string(e.Value))
+		match = e.isOpaque() || utils.RegexBinary(string(e.Value))
 	case utils.ElementTypeAlphaNumeric:
 		match = utils.RegexAlphaNumeric(string(e.Value))
 	case utils.ElementTypeAlphaSpecial:
@@ -552,6 +926,12 @@ func (e *Element) validateWithEncoding() error {
 		return errors.New(utils.ErrNonAvailableEncoding)
 	}
 
+	// Checking available encoding of opaque binary data in json and xml
+	if e.isOpaque() && e.TextEncoding != "" &&
+		e.TextEncoding != utils.EncodingHex && e.TextEncoding != utils.EncodingBase64 {
+		return errors.New(utils.ErrNonAvailableEncoding)
+	}
+
 	// Checking available encoding of length
 	if !e.Fixed {
 		available := utils.CheckAvailableEncoding(utils.ElementTypeNumberEncoding, e.LengthEncoding)
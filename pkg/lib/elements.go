@@ -17,12 +17,16 @@ import (
 
 // dummy struct for xml un-marshaling
 type xmlDataElement struct {
-	XMLName  xml.Name `xml:"DataElements"`
-	Text     string   `xml:",chardata"`
-	Elements []struct {
-		Text   string `xml:",chardata"`
-		Number int    `xml:"Number,attr"`
-	} `xml:"Element"`
+	XMLName  xml.Name     `xml:"DataElements"`
+	Text     string       `xml:",chardata"`
+	Elements []xmlElement `xml:"Element"`
+}
+
+// element of xml, subfields of composite element are nested elements
+type xmlElement struct {
+	Text     string       `xml:",chardata"`
+	Number   int          `xml:"Number,attr"`
+	Elements []xmlElement `xml:"Element"`
 }
 
 // create data elements of message with specification
@@ -30,6 +34,16 @@ func NewDataElements(spec *utils.Specification) (*dataElements, error) {
 	if spec == nil || spec.Elements == nil || spec.Encoding == nil {
 		return nil, errors.New(utils.ErrInvalidSpecification)
 	}
+	// element types are checked with encodings of the specification,
+	// invalid attributes are reported when their elements are used
+	for _, attr := range *spec.Elements {
+		if _type, err := attr.Parse(); err == nil {
+			_type.SetEncoding(spec.Encoding)
+			if err := _type.Validate(); err != nil {
+				return nil, err
+			}
+		}
+	}
 	return &dataElements{
 		elements: make(map[int]*Element),
 		spec:     spec,
@@ -42,14 +56,24 @@ type dataElements struct {
 	spec     *utils.Specification
 }
 
-// Validate check validation of field
+// Validate check validation of all fields, the error is ValidationReport
 func (e *dataElements) Validate() error {
-	for _, _element := range e.elements {
-		if err := _element.Validate(); err != nil {
-			return err
+	return e.validate().err()
+}
+
+// validate return errors of fields ordered by field number
+func (e *dataElements) validate() ValidationReport {
+	var report ValidationReport
+	for _, key := range e.Keys() {
+		if err := e.elements[key].Validate(); err != nil {
+			var attr *utils.Attribute
+			if e.spec != nil && e.spec.Elements != nil {
+				attr, _ = e.spec.Elements.Get(key)
+			}
+			report = append(report, newValidationError(key, attr, e.elements[key], err))
 		}
 	}
-	return nil
+	return report
 }
 
 // Bytes encode field to bytes
@@ -96,12 +120,24 @@ func (e *dataElements) UnmarshalJSON(b []byte) error {
 		if err != nil {
 			return err
 		}
-		_type, err := spec.Parse()
-		if err != nil {
+		if err := elm.setAttribute(spec, e.spec.Encoding); err != nil {
 			return err
 		}
-		_type.SetEncoding(e.spec.Encoding)
-		elm.setType(_type)
+		if isBitmapIndex(key) {
+			if err := elm.typeAsBitmap(spec); err != nil {
+				return err
+			}
+		}
+		if elm.rawJSON != nil {
+			if err := elm.decodeRawJSON(); err != nil {
+				return err
+			}
+		} else if elm.isOpaque() || elm.isComposite() || elm.isDataset() {
+			// value is text of binary data before the element is typed
+			if err := elm.setText(elm.Value); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -133,13 +169,7 @@ func (e *dataElements) MarshalXML(encoder *xml.Encoder, start xml.StartElement)
 	tokens := []xml.Token{start}
 
 	for _, key := range e.Keys() {
-		t := xml.StartElement{
-			Name: xml.Name{Local: utils.DataElementXmlName},
-			Attr: []xml.Attr{
-				{Name: xml.Name{Local: utils.DataElementAttrNumber}, Value: strconv.Itoa(key)},
-			},
-		}
-		tokens = append(tokens, t, xml.CharData(e.elements[key].String()), xml.EndElement{Name: t.Name})
+		tokens = append(tokens, elementTokens(key, e.elements[key])...)
 	}
 
 	tokens = append(tokens, xml.EndElement{Name: start.Name})
@@ -166,25 +196,18 @@ func (e *dataElements) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement
 	}
 
 	for _, element := range dummy.Elements {
-		var dataElement Element
-
 		spec, err := e.spec.Elements.Get(element.Number)
 		if err != nil {
 			return err
 		}
-		_type, err := spec.Parse()
+		dataElement, err := newDataElement(element.Number, spec, e.spec.Encoding)
 		if err != nil {
 			return err
 		}
-
-		_type.SetEncoding(e.spec.Encoding)
-		dataElement.setType(_type)
-		dataElement.Value = make([]byte, len(element.Text))
-		copy(dataElement.Value, element.Text)
-		if !dataElement.Fixed && len(dataElement.Value) > 0 {
-			dataElement.DataLength = len(dataElement.Value)
+		if err := dataElement.setXML(element); err != nil {
+			return err
 		}
-		e.elements[element.Number] = &dataElement
+		e.elements[element.Number] = dataElement
 	}
 
 	return nil
@@ -5,8 +5,10 @@
 package lib
 
 import (
+	"bytes"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"io/ioutil"
 	"path/filepath"
 	"testing"
@@ -1110,3 +1112,805 @@ func TestISO8583MessageWithJson(t *testing.T) {
 	_, err = NewSpecificationWithAttributes(jsonData, nil)
 	assert.Nil(t, err)
 }
+
+func TestISO8583MessageFieldAPI(t *testing.T) {
+	spec := &utils.Specification{
+		Elements: &utils.Attributes{
+			1:   {Describe: "b 64", Description: "Second Bitmap"},
+			3:   {Describe: "n 6", Description: "Processing code"},
+			11:  {Describe: "n 6", Description: "Systems trace audit number"},
+			41:  {Describe: "ans 8", Description: "Card acceptor terminal identification"},
+			65:  {Describe: "b 64", Description: "Tertiary Bitmap"},
+			70:  {Describe: "n 3", Description: "Network management information code"},
+			130: {Describe: "ans..11", Description: "Private data"},
+		},
+		Encoding: utils.DefaultMessageEncoding,
+	}
+	message, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+
+	assert.Nil(t, message.SetMTI("0800"))
+	assert.NotNil(t, message.SetMTI("08000"))
+	assert.NotNil(t, message.SetMTI("08AB"))
+
+	assert.Nil(t, message.SetField(3, "000000"))
+	assert.Nil(t, message.SetField(11, "123456"))
+	assert.Nil(t, message.Validate())
+	assert.Equal(t, "0010000000100000000000000000000000000000000000000000000000000000", message.GetBitmap().String())
+	assert.False(t, message.HasField(1))
+
+	buf, err := message.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, "08002020000000000000000000123456", string(buf))
+
+	// second and tertiary bitmaps
+	assert.Nil(t, message.SetField(70, "301"))
+	assert.Nil(t, message.SetField(130, "hello"))
+	assert.Nil(t, message.Validate())
+	assert.True(t, message.HasField(1))
+	assert.True(t, message.HasField(65))
+	value, err := message.GetField(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "1000010000000000000000000000000000000000000000000000000000000000", value)
+	value, err = message.GetField(65)
+	assert.Nil(t, err)
+	assert.Equal(t, "0100000000000000000000000000000000000000000000000000000000000000", value)
+
+	buf, err = message.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, "0800A02000000000000084000000000000000000001234564000000000000000301"+"05HELLO", string(buf))
+
+	loaded, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	read, err := loaded.Load(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, len(buf), read)
+	assert.Nil(t, loaded.Validate())
+	value, err = loaded.GetField(130)
+	assert.Nil(t, err)
+	assert.Equal(t, "HELLO", value)
+
+	// bitmaps are removed with the last field behind them
+	assert.Nil(t, message.UnsetField(130))
+	assert.False(t, message.HasField(65))
+	assert.Nil(t, message.UnsetField(70))
+	assert.False(t, message.HasField(1))
+	assert.Nil(t, message.UnsetField(70))
+	assert.Nil(t, message.Validate())
+	_, err = message.GetField(70)
+	assert.EqualError(t, err, utils.ErrNonExistElement)
+
+	// bitmaps are maintained by the message
+	assert.EqualError(t, message.SetField(1, "1"), utils.ErrInvalidElementIndex)
+	assert.EqualError(t, message.SetField(65, "1"), utils.ErrInvalidElementIndex)
+	assert.EqualError(t, message.UnsetField(65), utils.ErrInvalidElementIndex)
+	assert.EqualError(t, message.SetField(193, "1"), utils.ErrInvalidElementIndex)
+
+	// elements are typed by the specification
+	assert.EqualError(t, message.SetField(4, "1"), utils.ErrNonExistSpecification)
+	assert.EqualError(t, message.SetField(3, "ABC"), utils.ErrBadElementData)
+	assert.NotNil(t, message.SetField(3, "1234567"))
+	assert.NotNil(t, message.SetField(130, "hello world!"))
+
+	// fields above 128 need the tertiary bitmap in the specification
+	delete(*spec.Elements, 65)
+	assert.EqualError(t, message.SetField(130, "hello"), utils.ErrInvalidElementIndex)
+}
+
+func TestISO8583MessageWithOpaqueBinary(t *testing.T) {
+	encoding := *utils.DefaultMessageEncoding
+	encoding.BinaryEnc = utils.EncodingBinary
+	spec := utils.ISO8583DataElementsVer1987
+	spec.Encoding = &encoding
+	spec.MessageTypes = nil
+
+	// "b 64" is 8 bytes of PIN data, second bitmap keeps its bits
+	message, err := NewISO8583Message(&spec)
+	assert.Nil(t, err)
+	assert.Nil(t, message.SetMTI("0800"))
+	assert.Nil(t, message.SetField(52, "0123456789ABCDEF"))
+	assert.Nil(t, message.SetField(70, "301"))
+	assert.Nil(t, message.SetField(128, "1122334455667788"))
+	assert.Nil(t, message.Validate())
+
+	buf, err := message.Bytes()
+	assert.Nil(t, err)
+	expected := "0800" + "8000000000001000" + "0400000000000001" +
+		"\x01\x23\x45\x67\x89\xab\xcd\xef" + "301" + "\x11\x22\x33\x44\x55\x66\x77\x88"
+	assert.Equal(t, expected, string(buf))
+
+	loaded, err := NewISO8583Message(&spec)
+	assert.Nil(t, err)
+	read, err := loaded.Load(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, len(buf), read)
+	assert.Nil(t, loaded.Validate())
+	value, err := loaded.GetField(52)
+	assert.Nil(t, err)
+	assert.Equal(t, "0123456789ABCDEF", value)
+	value, err = loaded.GetField(70)
+	assert.Nil(t, err)
+	assert.Equal(t, "301", value)
+	value, err = loaded.GetField(128)
+	assert.Nil(t, err)
+	assert.Equal(t, "1122334455667788", value)
+	buf, err = loaded.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(buf))
+
+	// variable binary data
+	elements := utils.Attributes{}
+	for index, attr := range *utils.ISO8583DataElementsVer1987.Elements {
+		elements[index] = attr
+	}
+	elements[55] = utils.Attribute{Describe: "b...255", Description: "ICC data"}
+	spec.Elements = &elements
+	message, err = NewISO8583Message(&spec)
+	assert.Nil(t, err)
+	assert.Nil(t, message.SetMTI("0200"))
+	assert.Nil(t, message.SetField(3, "000000"))
+	assert.Nil(t, message.SetField(52, "0123456789ABCDEF"))
+	assert.Nil(t, message.SetField(55, "9F2608C2A1"))
+	assert.Nil(t, message.SetField(70, "301"))
+	assert.Nil(t, message.Validate())
+
+	// raw bytes on the wire
+	buf, err = message.Bytes()
+	assert.Nil(t, err)
+	expected = "0200A000000000001200" + "0400000000000000" + "000000" +
+		"\x01\x23\x45\x67\x89\xab\xcd\xef" + "005\x9f\x26\x08\xc2\xa1" + "301"
+	assert.Equal(t, expected, string(buf))
+
+	loaded, err = NewISO8583Message(&spec)
+	assert.Nil(t, err)
+	_, err = loaded.Load(buf)
+	assert.Nil(t, err)
+	assert.Nil(t, loaded.Validate())
+	assert.Equal(t, []byte{0x9f, 0x26, 0x08, 0xc2, 0xa1}, loaded.GetElements()[55].Value)
+	value, err = loaded.GetField(52)
+	assert.Nil(t, err)
+	assert.Equal(t, "0123456789ABCDEF", value)
+
+	// hex in json and xml
+	jsonBuf, err := json.Marshal(loaded)
+	assert.Nil(t, err)
+	assert.Contains(t, string(jsonBuf), `"52":"0123456789ABCDEF","55":"9F2608C2A1"`)
+	fromJSON, err := NewISO8583Message(&spec)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(jsonBuf, fromJSON))
+	assert.Equal(t, loaded.GetElements()[55].Value, fromJSON.GetElements()[55].Value)
+
+	xmlBuf, err := xml.Marshal(loaded)
+	assert.Nil(t, err)
+	assert.Contains(t, string(xmlBuf), `<Element Number="55">9F2608C2A1</Element>`)
+	fromXML, err := NewISO8583Message(&spec)
+	assert.Nil(t, err)
+	assert.Nil(t, xml.Unmarshal(xmlBuf, fromXML))
+	assert.Equal(t, loaded.GetElements()[55].Value, fromXML.GetElements()[55].Value)
+	assert.Equal(t, 5, fromXML.GetElements()[55].DataLength)
+
+	// base64 in json, hex characters on the wire
+	encoding.BinaryTextEnc = utils.EncodingBase64
+	encoding.BinaryEnc = utils.EncodingHexBinary
+	message, err = NewISO8583Message(&spec)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal([]byte(`{"mti":"0200","bitmap":"0000000000000000000000000000000000000000000000000000001000000000","elements":{"55":"nyYIwqE="}}`), message))
+	assert.Nil(t, message.Validate())
+	buf, err = message.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, "02000000000000000200"+"0059F2608C2A1", string(buf))
+	jsonBuf, err = json.Marshal(message)
+	assert.Nil(t, err)
+	assert.Contains(t, string(jsonBuf), `"55":"nyYIwqE="`)
+
+	loaded, err = NewISO8583Message(&spec)
+	assert.Nil(t, err)
+	_, err = loaded.Load(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x9f, 0x26, 0x08, 0xc2, 0xa1}, loaded.GetElements()[55].Value)
+
+	// malformed data
+	_, err = loaded.Load([]byte("02000000000000000200" + "0059F2608C2AZ"))
+	assert.EqualError(t, err, utils.ErrBadElementData)
+	assert.EqualError(t, message.SetField(55, "not base64"), utils.ErrBadElementData)
+	assert.NotNil(t, message.SetField(52, "AQIDBAUGBwgJ"))
+
+	// fixed binary data must fill the element, like it's required by Bytes
+	assert.EqualError(t, message.SetField(52, "ASNF"), utils.ErrBadBinary)
+	assert.Nil(t, message.Validate())
+}
+
+func TestISO8583MessageWithBinaryBitmap(t *testing.T) {
+	encoding := *utils.DefaultMessageEncoding
+	encoding.BitmapEnc = utils.EncodingBinary
+	encoding.BinaryEnc = utils.EncodingBinary
+	spec := &utils.Specification{
+		Elements: &utils.Attributes{
+			1:   {Describe: "b 64", Description: "Second Bitmap"},
+			3:   {Describe: "n 6", Description: "Processing code"},
+			65:  {Describe: "b 64", Description: "Tertiary Bitmap"},
+			70:  {Describe: "n 3", Description: "Network management information code"},
+			130: {Describe: "ans 5", Description: "Private data"},
+		},
+		Encoding: &encoding,
+	}
+	message, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, message.SetMTI("0800"))
+	assert.Nil(t, message.SetField(3, "000000"))
+	assert.Nil(t, message.SetField(70, "301"))
+	assert.Nil(t, message.Validate())
+
+	buf, err := message.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, "0800\xa0\x00\x00\x00\x00\x00\x00\x00\x04\x00\x00\x00\x00\x00\x00\x00000000301", string(buf))
+
+	loaded, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	read, err := loaded.Load(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, len(buf), read)
+	assert.Nil(t, loaded.Validate())
+	assert.Equal(t, message.GetBitmap().String(), loaded.GetBitmap().String())
+
+	// tertiary bitmap
+	assert.Nil(t, message.SetField(130, "hello"))
+	buf, err = message.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, "0800\xa0\x00\x00\x00\x00\x00\x00\x00\x84\x00\x00\x00\x00\x00\x00\x00000000\x40\x00\x00\x00\x00\x00\x00\x00301HELLO", string(buf))
+
+	loaded, err = NewISO8583Message(spec)
+	assert.Nil(t, err)
+	_, err = loaded.Load(buf)
+	assert.Nil(t, err)
+	assert.Nil(t, loaded.Validate())
+	value, err := loaded.GetField(130)
+	assert.Nil(t, err)
+	assert.Equal(t, "HELLO", value)
+
+	// bitmap is too short
+	_, err = loaded.Load([]byte("0800\xa0\x00\x00"))
+	assert.EqualError(t, err, utils.ErrBadElementData)
+}
+
+func TestISO8583MessageWithLengthPrefix(t *testing.T) {
+	encoding := *utils.DefaultMessageEncoding
+	encoding.LengthEnc = utils.EncodingBinary
+	encoding.BinaryEnc = utils.EncodingBinary
+	spec := &utils.Specification{
+		Elements: &utils.Attributes{
+			1:  {Describe: "b 64", Description: "Second Bitmap"},
+			2:  {Describe: "n..19", Description: "Primary account number (PAN)"},
+			44: {Describe: "ans..25", Description: "Additional response data", LengthPrefix: "LL"},
+			55: {Describe: "b...999", Description: "ICC data"},
+			60: {Describe: "ans...999", Description: "Reserved", LengthPrefix: "LLLL"},
+		},
+		Encoding: &encoding,
+	}
+	message, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, message.SetMTI("0100"))
+	assert.Nil(t, message.SetField(2, "4242424242424242"))
+	assert.Nil(t, message.SetField(44, "OK"))
+	assert.Nil(t, message.SetField(55, "9F2608C2A1"))
+	assert.Nil(t, message.SetField(60, "RESERVED"))
+	assert.Nil(t, message.Validate())
+
+	// big-endian binary length of 1, 2 and 4 bytes
+	buf, err := message.Bytes()
+	assert.Nil(t, err)
+	expected := "01004000000000100210" +
+		"\x104242424242424242" + "\x00\x02OK" + "\x00\x05\x9f\x26\x08\xc2\xa1" + "\x00\x00\x00\x08RESERVED"
+	assert.Equal(t, expected, string(buf))
+
+	loaded, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	read, err := loaded.Load(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, len(buf), read)
+	value, err := loaded.GetField(60)
+	assert.Nil(t, err)
+	assert.Equal(t, "RESERVED", value)
+
+	// length is longer than the element
+	_, err = loaded.Load([]byte("0100" + "4000000000000000" + "\x144242424242424242424242"))
+	assert.EqualError(t, err, utils.ErrInvalidElementLength)
+
+	// 3 digit prefix for a field up to 99
+	encoding.LengthEnc = utils.EncodingChar
+	(*spec.Elements)[44] = utils.Attribute{Describe: "ans..99", LengthPrefix: "LLL"}
+	message, err = NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, message.SetMTI("0100"))
+	assert.Nil(t, message.SetField(44, "OK"))
+	buf, err = message.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, "01000000000000100000"+"002OK", string(buf))
+	loaded, err = NewISO8583Message(spec)
+	assert.Nil(t, err)
+	_, err = loaded.Load(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "OK", loaded.GetElements()[44].String())
+
+	// prefix is too narrow
+	(*spec.Elements)[44] = utils.Attribute{Describe: "ans..99", LengthPrefix: "L"}
+	message, err = NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, message.SetMTI("0100"))
+	assert.EqualError(t, message.SetField(44, "TOO LONG FOR L"), utils.ErrInvalidElementLength)
+
+	// binary prefix is 1, 2 or 4 bytes, it's checked when the specification
+	// is loaded
+	encoding.LengthEnc = utils.EncodingBinary
+	(*spec.Elements)[44] = utils.Attribute{Describe: "ans..99", LengthPrefix: "LLL"}
+	_, err = NewISO8583Message(spec)
+	assert.EqualError(t, err, utils.ErrInvalidLengthHead)
+	assert.EqualError(t, message.SetField(44, "OK"), utils.ErrInvalidLengthHead)
+	_, err = utils.Attribute{Describe: "ans..99", LengthPrefix: "LLL", LengthEncoding: utils.EncodingBinary}.Parse()
+	assert.EqualError(t, err, utils.ErrInvalidLengthHead)
+
+	// prefix is given for variable elements only
+	(*spec.Elements)[44] = utils.Attribute{Describe: "ans 25", LengthPrefix: "LL"}
+	assert.EqualError(t, message.SetField(44, "OK"), utils.ErrInvalidLengthHead)
+	(*spec.Elements)[44] = utils.Attribute{Describe: "ans..25", LengthPrefix: "NN"}
+	assert.EqualError(t, message.SetField(44, "OK"), utils.ErrInvalidLengthHead)
+}
+
+func TestISO8583MessageWithElementEncodings(t *testing.T) {
+	encoding := *utils.DefaultMessageEncoding
+	encoding.NumberEnc = utils.EncodingBcd
+	encoding.LengthEnc = utils.EncodingRBcd
+	spec := &utils.Specification{
+		Elements: &utils.Attributes{
+			1:  {Describe: "b 64", Description: "Second Bitmap"},
+			3:  {Describe: "n 6", Description: "Processing code"},
+			32: {Describe: "n..11", Description: "Acquiring institution identification code", Encoding: utils.EncodingChar, LengthEncoding: utils.EncodingChar},
+			41: {Describe: "ans 8", Description: "Card acceptor terminal identification", Padding: "0", Justify: utils.JustifyRight},
+			43: {Describe: "ans 6", Description: "Card acceptor name/location", Encoding: utils.EncodingEbcdic, Padding: "*"},
+			49: {Describe: "n 4", Description: "Currency code, transaction", Encoding: utils.EncodingChar, Padding: " ", Justify: utils.JustifyLeft},
+		},
+		Encoding: &encoding,
+	}
+	message, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, message.SetMTI("0100"))
+	assert.Nil(t, message.SetField(3, "000000"))
+	assert.Nil(t, message.SetField(32, "123456"))
+	assert.Nil(t, message.SetField(41, "1234"))
+	assert.Nil(t, message.SetField(43, "ABC"))
+	assert.Nil(t, message.SetField(49, "840"))
+	assert.Nil(t, message.Validate())
+
+	// global encodings apply to the elements without overrides
+	buf, err := message.Bytes()
+	assert.Nil(t, err)
+	expected := "0100" + "2000000100A08000" + "\x00\x00\x00" + "06123456" + "00001234" + "\xc1\xc2\xc3\x5c\x5c\x5c" + "840 "
+	assert.Equal(t, expected, string(buf))
+
+	loaded, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	read, err := loaded.Load(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, len(buf), read)
+	assert.Equal(t, "123456", loaded.GetElements()[32].String())
+	assert.Equal(t, utils.EncodingChar, loaded.GetElements()[32].Encoding)
+	assert.Equal(t, utils.EncodingBcd, loaded.GetElements()[3].Encoding)
+
+	// overrides are checked like the global encodings
+	(*spec.Elements)[3] = utils.Attribute{Describe: "n 6", Encoding: utils.EncodingEbcdic}
+	assert.EqualError(t, message.SetField(3, "000000"), utils.ErrNonAvailableEncoding)
+	(*spec.Elements)[3] = utils.Attribute{Describe: "n 6", Justify: "CENTER"}
+	assert.EqualError(t, message.SetField(3, "000000"), utils.ErrInvalidPadding)
+	(*spec.Elements)[3] = utils.Attribute{Describe: "n 6", Padding: "00"}
+	assert.EqualError(t, message.SetField(3, "000000"), utils.ErrInvalidPadding)
+}
+
+func TestISO8583MessageWithSubfields(t *testing.T) {
+	spec := &utils.Specification{
+		Elements: &utils.Attributes{
+			1: {Describe: "b 64", Description: "Second Bitmap"},
+			48: {Describe: "ans...999", Description: "Additional data", Subfields: utils.Attributes{
+				1: {Describe: "an 2", Description: "Tag"},
+				2: {Describe: "ans..20", Description: "Merchant", Subfields: utils.Attributes{
+					1: {Describe: "n 4", Description: "Category"},
+					2: {Describe: "ans..16", Description: "Name"},
+				}},
+				3: {Describe: "ans..10", Description: "Reference"},
+			}},
+			62: {Describe: "ans...999", Description: "Private data", SubfieldBitmap: 8, Subfields: utils.Attributes{
+				1: {Describe: "n 2", Description: "Code"},
+				3: {Describe: "ans..8", Description: "Note"},
+			}},
+			90: {Describe: "n 42", Description: "Original data elements", Subfields: utils.Attributes{
+				1: {Describe: "n 4", Description: "Original MTI"},
+				2: {Describe: "n 6", Description: "Original STAN"},
+				3: {Describe: "n 10", Description: "Original transmission date and time"},
+				4: {Describe: "n 11", Description: "Original acquirer"},
+				5: {Describe: "n 11", Description: "Original forwarder"},
+			}},
+		},
+		Encoding: utils.DefaultMessageEncoding,
+	}
+	message, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, message.SetMTI("0420"))
+	assert.Nil(t, message.SetSubfield("48.1", "AB"))
+	assert.Nil(t, message.SetSubfield("48.2.1", "5411"))
+	assert.Nil(t, message.SetSubfield("48.2.2", "MOOV"))
+	assert.Nil(t, message.SetSubfield("62.3", "NOTE"))
+	assert.Nil(t, message.SetSubfield("90.1", "0200"))
+	assert.Nil(t, message.SetSubfield("90.2", "123456"))
+	assert.Nil(t, message.Validate())
+
+	value, err := message.GetSubfield("48.2.2")
+	assert.Nil(t, err)
+	assert.Equal(t, "MOOV", value)
+	value, err = message.GetSubfield("48.2")
+	assert.Nil(t, err)
+	assert.Equal(t, "541104MOOV", value)
+	_, err = message.GetSubfield("48.3")
+	assert.EqualError(t, err, utils.ErrNonExistElement)
+
+	// absent subfields of fixed element are empty, subfields of 62 follow its bitmap
+	buf, err := message.Bytes()
+	assert.Nil(t, err)
+	expected := "0420" + "8000000000010004" + "0000004000000000" +
+		"014" + "AB" + "10" + "5411" + "04MOOV" +
+		"007" + "20" + "4NOTE" +
+		"0200" + "123456" + "0000000000" + "00000000000" + "00000000000"
+	assert.Equal(t, expected, string(buf))
+
+	loaded, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	read, err := loaded.Load(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, len(buf), read)
+	assert.Nil(t, loaded.Validate())
+	value, err = loaded.GetSubfield("48.2.1")
+	assert.Nil(t, err)
+	assert.Equal(t, "5411", value)
+	value, err = loaded.GetSubfield("90.2")
+	assert.Nil(t, err)
+	assert.Equal(t, "123456", value)
+	assert.Nil(t, loaded.GetElements()[62].Subfields[1])
+
+	// subfields are nested objects of json
+	jsonBuf, err := json.Marshal(loaded)
+	assert.Nil(t, err)
+	assert.Contains(t, string(jsonBuf), `"48":{"1":"AB","2":{"1":"5411","2":"MOOV"}}`)
+	assert.Contains(t, string(jsonBuf), `"62":{"3":"NOTE"}`)
+	fromJSON, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(jsonBuf, fromJSON))
+	jsonBuf, err = fromJSON.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(jsonBuf))
+
+	// and nested elements of xml
+	xmlBuf, err := xml.Marshal(loaded)
+	assert.Nil(t, err)
+	assert.Contains(t, string(xmlBuf), `<Element Number="48"><Element Number="1">AB</Element><Element Number="2"><Element Number="1">5411</Element><Element Number="2">MOOV</Element></Element></Element>`)
+	fromXML, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, xml.Unmarshal(xmlBuf, fromXML))
+	xmlBuf, err = fromXML.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(xmlBuf))
+
+	// packed subfields are set as value of the element
+	assert.Nil(t, message.SetField(48, "CD08541102MO"))
+	value, err = message.GetSubfield("48.2.2")
+	assert.Nil(t, err)
+	assert.Equal(t, "MO", value)
+	value, err = message.GetField(48)
+	assert.Nil(t, err)
+	assert.Equal(t, "CD08541102MO", value)
+
+	// subfields are typed by the specification
+	assert.EqualError(t, message.SetSubfield("48.4", "X"), utils.ErrNonExistSpecification)
+	assert.EqualError(t, message.SetSubfield("48.1.1", "X"), utils.ErrNonExistSpecification)
+	assert.EqualError(t, message.SetSubfield("90.1", "ABCD"), utils.ErrBadElementData)
+	assert.EqualError(t, message.SetSubfield("62.9", "1"), utils.ErrInvalidElementIndex)
+	assert.EqualError(t, message.SetSubfield("48.x", "1"), utils.ErrInvalidElementIndex)
+	assert.EqualError(t, message.SetField(48, "CD1054"), utils.ErrBadElementData)
+}
+
+func TestTLV(t *testing.T) {
+	value := bytes.Repeat([]byte{0xAB}, 200)
+	raw := append([]byte{
+		0x9F, 0x26, 0x02, 0x11, 0x22,
+		0x71, 0x06, 0x86, 0x04, 0x84, 0x18, 0x00, 0x00,
+		0xDF, 0x81, 0x01, 0x01, 0x01,
+		0x9F, 0x7C, 0x81, 0xC8,
+	}, value...)
+	tlvs, err := DecodeTLV(raw)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(tlvs))
+	assert.Equal(t, TLV{Tag: "9F26", Value: []byte{0x11, 0x22}}, tlvs[0])
+	assert.Equal(t, "Application Cryptogram", tlvs[0].Name())
+	assert.True(t, tlvs[1].Constructed())
+	assert.Equal(t, []TLV{{Tag: "86", Value: []byte{0x84, 0x18, 0x00, 0x00}}}, tlvs[1].Children)
+	assert.Equal(t, "DF8101", tlvs[2].Tag)
+	assert.Equal(t, "", tlvs[2].Name())
+	assert.Equal(t, value, tlvs[3].Value)
+
+	// lengths are encoded in the shortest form
+	encoded, err := EncodeTLV(tlvs)
+	assert.Nil(t, err)
+	assert.Equal(t, raw, encoded)
+	encoded, err = EncodeTLV([]TLV{{Tag: "9F7C", Value: bytes.Repeat([]byte{0x01}, 300)}})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x9F, 0x7C, 0x82, 0x01, 0x2C}, encoded[:5])
+
+	// malformed data objects
+	for _, bad := range [][]byte{
+		{0x9F},
+		{0x9F, 0x26},
+		{0x9F, 0x26, 0x03, 0x11},
+		{0x9F, 0x26, 0x80},
+		{0x9F, 0x26, 0x85, 0x01, 0x01, 0x01, 0x01, 0x01},
+		{0x71, 0x02, 0x86, 0x04},
+	} {
+		_, err = DecodeTLV(bad)
+		assert.EqualError(t, err, utils.ErrBadTLV)
+	}
+	_, err = EncodeTLV([]TLV{{Tag: "9F"}})
+	assert.EqualError(t, err, utils.ErrBadTLV)
+	_, err = EncodeTLV([]TLV{{Tag: "95", Children: tlvs}})
+	assert.EqualError(t, err, utils.ErrBadTLV)
+}
+
+func TestISO8583MessageWithTLV(t *testing.T) {
+	spec := &utils.Specification{
+		Elements: &utils.Attributes{
+			1:  {Describe: "b 64", Description: "Second Bitmap"},
+			55: {Describe: "b...255", Description: "ICC data", Encoding: utils.EncodingBinary, TLV: true},
+		},
+		Encoding: utils.DefaultMessageEncoding,
+	}
+	message, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, message.SetMTI("0100"))
+	assert.Nil(t, message.SetField(55, "9F260811223344556677889F2701809A032006137106860484180000"))
+	assert.Nil(t, message.Validate())
+
+	tlvs, err := message.GetElements()[55].TLVs()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"9F26", "9F27", "9A", "71"}, []string{tlvs[0].Tag, tlvs[1].Tag, tlvs[2].Tag, tlvs[3].Tag})
+
+	buf, err := message.Bytes()
+	assert.Nil(t, err)
+	loaded, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	_, err = loaded.Load(buf)
+	assert.Nil(t, err)
+	value, err := loaded.GetField(55)
+	assert.Nil(t, err)
+	assert.Equal(t, "9F260811223344556677889F2701809A032006137106860484180000", value)
+
+	// data objects are json array with names of EMV tags
+	jsonBuf, err := json.Marshal(loaded)
+	assert.Nil(t, err)
+	assert.Contains(t, string(jsonBuf), `{"tag":"9F26","name":"Application Cryptogram","value":"1122334455667788"}`)
+	assert.Contains(t, string(jsonBuf), `{"tag":"71","name":"Issuer Script Template 1","children":[{"tag":"86","name":"Issuer Script Command","value":"84180000"}]}`)
+	fromJSON, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(jsonBuf, fromJSON))
+	jsonBuf, err = fromJSON.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, buf, jsonBuf)
+
+	// malformed data objects are rejected, and kept as hex in json
+	assert.EqualError(t, message.SetField(55, "9F2608"), utils.ErrBadTLV)
+	message.GetElements()[55].Value = []byte{0x9F, 0x26, 0x08}
+	assert.EqualError(t, message.Validate(), "field 55 (ICC data): tlv: "+utils.ErrBadTLV)
+	jsonBuf, err = json.Marshal(message)
+	assert.Nil(t, err)
+	assert.Contains(t, string(jsonBuf), `"55":"9F2608"`)
+
+	// data objects are opaque binary data
+	spec.Elements = &utils.Attributes{55: {Describe: "b...255", TLV: true}}
+	message, err = NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.EqualError(t, message.SetField(55, "1111"), utils.ErrNonAvailableEncoding)
+	_, err = utils.Attribute{Describe: "ans...255", TLV: true}.Parse()
+	assert.EqualError(t, err, utils.ErrInvalidElementType)
+}
+
+func TestISO8583MessageWithDataset(t *testing.T) {
+	spec := &utils.Specification{
+		Elements: &utils.Attributes{
+			1: {Describe: "b 64", Description: "Second Bitmap"},
+			48: {Describe: "ans...999", Description: "Additional data", Dataset: &utils.Dataset{
+				TagWidth:    2,
+				LengthWidth: 2,
+				Tags: map[string]utils.Attribute{
+					"01": {Describe: "n 4", Description: "Category"},
+					"02": {Describe: "ans..16", Description: "Name"},
+				},
+			}},
+			62: {Describe: "ans...999", Description: "Private data", Dataset: &utils.Dataset{
+				TagWidth:       3,
+				LengthWidth:    1,
+				LengthEncoding: utils.EncodingBinary,
+				Unknown:        utils.UnknownTagsReject,
+				Tags: map[string]utils.Attribute{
+					"REF": {Describe: "ans..10", Description: "Reference"},
+				},
+			}},
+		},
+		Encoding: utils.DefaultMessageEncoding,
+	}
+	message, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, message.SetMTI("0100"))
+	// tags are packed in order of tags, unknown tags of 48 are passed through
+	assert.Nil(t, message.SetField(48, "02041234"+"ZZ03ABC"+"01045411"))
+	assert.Nil(t, message.SetField(62, "REF\x02AB"))
+	assert.Nil(t, message.Validate())
+
+	elm := message.GetElements()[48]
+	assert.Equal(t, map[string]string{"01": "5411", "02": "1234", "ZZ": "ABC"}, elm.Tags)
+	value, err := message.GetField(48)
+	assert.Nil(t, err)
+	assert.Equal(t, "01045411"+"02041234"+"ZZ03ABC", value)
+
+	buf, err := message.Bytes()
+	assert.Nil(t, err)
+	expected := "0100" + "0000000000010004" + "023" + "01045411" + "02041234" + "ZZ03ABC" + "006" + "REF\x02AB"
+	assert.Equal(t, expected, string(buf))
+	loaded, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	_, err = loaded.Load(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"REF": "AB"}, loaded.GetElements()[62].Tags)
+
+	// tags are json object
+	jsonBuf, err := json.Marshal(loaded)
+	assert.Nil(t, err)
+	assert.Contains(t, string(jsonBuf), `"48":{"01":"5411","02":"1234","ZZ":"ABC"}`)
+	fromJSON, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(jsonBuf, fromJSON))
+	jsonBuf, err = fromJSON.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(jsonBuf))
+
+	// tags are set one by one and checked by known attributes
+	assert.Nil(t, elm.SetTag("01", "5999"))
+	assert.EqualError(t, elm.SetTag("01", "59"), utils.ErrInvalidElementLength)
+	assert.EqualError(t, elm.SetTag("01", "ABCD"), utils.ErrBadElementData)
+	assert.EqualError(t, elm.SetTag("1", "5999"), utils.ErrInvalidDataset)
+	assert.EqualError(t, message.GetElements()[62].SetTag("XYZ", "1"), utils.ErrUnknownTag+": XYZ")
+	assert.EqualError(t, message.SetField(62, "XYZ\x01A"), utils.ErrUnknownTag+": XYZ")
+	assert.EqualError(t, message.SetField(48, "0104541101045411"), utils.ErrBadElementData)
+	elm.Tags["01"] = "ABCD"
+	assert.EqualError(t, message.Validate(), "field 48 (Additional data): type: "+utils.ErrBadElementData)
+
+	// datasets are characters with valid widths
+	_, err = utils.Attribute{Describe: "b...999", Dataset: &utils.Dataset{TagWidth: 2, LengthWidth: 2}}.Parse()
+	assert.EqualError(t, err, utils.ErrInvalidElementType)
+	_, err = utils.Attribute{Describe: "ans...999", Dataset: &utils.Dataset{TagWidth: 2}}.Parse()
+	assert.EqualError(t, err, utils.ErrInvalidDataset)
+	_, err = utils.Attribute{Describe: "ans...999", Dataset: &utils.Dataset{TagWidth: 2, LengthWidth: 2, Bitmap: 64}}.Parse()
+	assert.EqualError(t, err, utils.ErrBitmapDataset)
+	_, err = utils.Attribute{Describe: "ans...999", Dataset: &utils.Dataset{TagWidth: 2, LengthWidth: 2, Unknown: "DROP"}}.Parse()
+	assert.EqualError(t, err, utils.ErrInvalidDataset)
+	_, err = utils.Attribute{Describe: "ans...999", Dataset: &utils.Dataset{TagWidth: 2, LengthWidth: 2, Tags: map[string]utils.Attribute{"001": {Describe: "n 4"}}}}.Parse()
+	assert.EqualError(t, err, utils.ErrInvalidDataset)
+}
+
+func TestISO8583MessageValidationReport(t *testing.T) {
+	spec := &utils.Specification{
+		Elements: &utils.Attributes{
+			1:  {Describe: "b 64", Description: "Second Bitmap"},
+			2:  {Describe: "n..19", Description: "Primary account number (PAN)"},
+			3:  {Describe: "n 6", Description: "Processing code"},
+			11: {Describe: "n 6", Description: "Systems trace audit number (STAN)"},
+			13: {Describe: "n 4; MMDD", Description: "Local transaction date"},
+			41: {Describe: "ans 8", Description: "Card acceptor terminal identification", Sensitive: true},
+		},
+		Encoding: utils.DefaultMessageEncoding,
+		MessageTypes: &utils.MessageTypes{
+			"0100": {MandatoryHexMask: "60200000000000000000000000000000", OptionalHexMask: "00080000000000000000000000000000"},
+		},
+	}
+	message, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, message.SetMTI("0100"))
+	assert.Nil(t, message.SetField(2, "4111111111111111"))
+	assert.Nil(t, message.SetField(13, "1231"))
+	assert.Nil(t, message.SetField(41, "TERM0001"))
+	assert.Nil(t, message.SetField(11, "123456"))
+	elements := message.GetElements()
+	elements[2].Value = []byte("4111111111111A11")
+	elements[13].Value = []byte("1341")
+
+	// all violated rules are reported in order of fields
+	err = message.Validate()
+	var report ValidationReport
+	assert.True(t, errors.As(err, &report))
+	assert.Equal(t, 4, len(report))
+	assert.Equal(t, &ValidationError{Field: 2, Description: "Primary account number (PAN)", Value: "411111******1A11", Rule: RuleType, Err: errors.New(utils.ErrBadElementData)}, report[0])
+	assert.Equal(t, &ValidationError{Field: 13, Description: "Local transaction date", Value: "1341", Rule: RuleFormat, Err: errors.New(utils.ErrBadElementData)}, report[1])
+	assert.Equal(t, 41, report[2].Field)
+	assert.Equal(t, RuleUnexpectedField, report[2].Rule)
+	assert.Equal(t, "********", report[2].Value)
+	assert.Equal(t, &ValidationError{Field: 3, Description: "Processing code", Rule: RuleMandatoryField, Err: errors.New(utils.ErrMandatoryField)}, report[3])
+	assert.Equal(t, "field 2 (Primary account number (PAN)): type: "+utils.ErrBadElementData, report[0].Error())
+	assert.Contains(t, err.Error(), "field 13 (Local transaction date): format: "+utils.ErrBadElementData+"; ")
+	assert.Equal(t, utils.ErrBadElementData, errors.Unwrap(report[0]).Error())
+
+	// report is a list of json objects
+	buf, err := json.Marshal(report[:2])
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"field":2,"description":"Primary account number (PAN)","value":"411111******1A11","rule":"type","error":"bad element data"},`+
+		`{"field":13,"description":"Local transaction date","value":"1341","rule":"format","error":"bad element data"}]`, string(buf))
+
+	// rule of element validation
+	err = elements[13].Validate()
+	var ruleErr *RuleError
+	assert.True(t, errors.As(err, &ruleErr))
+	assert.Equal(t, RuleFormat, ruleErr.Rule)
+	assert.EqualError(t, err, utils.ErrBadElementData)
+}
+
+func TestISO8583MessageWithConstraints(t *testing.T) {
+	spec, err := NewSpecificationWithJson([]byte(`{
+		"elements": {
+			"2": {"Describe": "n..19", "Description": "Primary account number (PAN)", "Luhn": true, "MinLength": 12},
+			"4": {"Describe": "n 12", "Description": "Amount, transaction", "Min": 1, "Max": 100000},
+			"22": {"Describe": "n 3", "Description": "Point of service entry mode", "Values": ["010", "051", "071"]},
+			"39": {"Describe": "an 2", "Description": "Response code", "Values": ["00", "05", "51"]},
+			"41": {"Describe": "ans 8", "Description": "Card acceptor terminal identification", "Regex": "T[0-9]{7}"}
+		}
+	}`))
+	assert.Nil(t, err)
+	message, err := NewISO8583Message(spec)
+	assert.Nil(t, err)
+	assert.Nil(t, message.SetMTI("0110"))
+	assert.Nil(t, message.SetField(2, "4111111111111111"))
+	assert.Nil(t, message.SetField(4, "000000005000"))
+	assert.Nil(t, message.SetField(22, "051"))
+	assert.Nil(t, message.SetField(39, "05"))
+	assert.Nil(t, message.SetField(41, "T0000001"))
+	assert.Nil(t, message.Validate())
+
+	// values violating constraints are rejected with names of the rules
+	for _, c := range []struct {
+		index int
+		value string
+		rule  string
+	}{
+		{2, "4111111111111112", RuleLuhn},
+		{2, "4111111116", RuleMinLength},
+		{4, "000000000000", RuleMin},
+		{4, "000000100001", RuleMax},
+		{22, "052", RuleValues},
+		{39, "99", RuleValues},
+		{41, "T000001X", RuleRegex},
+		{41, "XT000001", RuleRegex},
+	} {
+		err = message.SetField(c.index, c.value)
+		var ruleErr *RuleError
+		assert.True(t, errors.As(err, &ruleErr), c.value)
+		assert.Equal(t, c.rule, ruleErr.Rule, c.value)
+		assert.EqualError(t, err, utils.ErrViolatedConstraint+": "+c.rule)
+	}
+
+	// the report of the message names the rules
+	message.GetElements()[39].Value = []byte("99")
+	message.GetElements()[4].Value = []byte("000000000000")
+	var report ValidationReport
+	assert.True(t, errors.As(message.Validate(), &report))
+	assert.Equal(t, 2, len(report))
+	assert.Equal(t, RuleMin, report[0].Rule)
+	assert.Equal(t, RuleValues, report[1].Rule)
+
+	// constraints of the specification are checked
+	_, err = utils.Attribute{Describe: "ans 8", Constraints: utils.Constraints{Regex: "T["}}.Parse()
+	assert.EqualError(t, err, utils.ErrInvalidConstraint)
+	min, max := int64(10), int64(1)
+	_, err = utils.Attribute{Describe: "n 4", Constraints: utils.Constraints{Min: &min, Max: &max}}.Parse()
+	assert.EqualError(t, err, utils.ErrInvalidConstraint)
+}
@@ -25,6 +25,13 @@ type Iso8583Message interface {
 	GetElements() map[int]*Element
 	GetMti() *Element
 	GetBitmap() *Element
+	SetMTI(mti string) error
+	SetField(index int, value string) error
+	GetField(index int) (string, error)
+	UnsetField(index int) error
+	HasField(index int) bool
+	GetSubfield(path string) (string, error)
+	SetSubfield(path string, value string) error
 }
 
 // public functions of lib
@@ -106,35 +113,34 @@ type messageJSON struct {
 	Elements *dataElements `xml:"DataElements,omitempty" json:"elements,omitempty"`
 }
 
-// Validate check validation of field
+// Validate check validation of message, the error is ValidationReport of
+// all violated rules
 func (m *isoMessage) Validate() error {
+	var report ValidationReport
 	if m.mti != nil {
 		if err := m.mti.Validate(); err != nil {
-			return err
+			report = append(report, newValidationError(0, &utils.Attribute{Description: "Message Type Indicator"}, m.mti, err))
 		}
 	}
 	if m.bitmap != nil {
 		if err := m.bitmap.Validate(); err != nil {
-			return err
+			report = append(report, newValidationError(0, &utils.Attribute{Description: "Bitmap"}, m.bitmap, err))
 		}
 	}
 	if m.elements != nil {
-		if err := m.elements.Validate(); err != nil {
-			return err
-		}
+		report = append(report, m.elements.validate()...)
 	}
 
 	m.generateIndexes()
 	if !reflect.DeepEqual(m.elements.Keys(), m.indexes) {
-		return errors.New(utils.ErrMisMatchElementsBitmap)
+		err := ruleError(RuleBitmap, errors.New(utils.ErrMisMatchElementsBitmap))
+		report = append(report, newValidationError(0, &utils.Attribute{Description: "Bitmap"}, m.bitmap, err))
 	}
 
 	if mType, exist := m.isValidMessageType(); exist {
-		if err := m.validateMessageField(mType); err != nil {
-			return err
-		}
+		report = append(report, m.validateMessageField(mType)...)
 	}
-	return nil
+	return report.err()
 }
 
 // Bytes encode field to bytes
@@ -197,7 +203,8 @@ func (m *isoMessage) Load(raw []byte) (int, error) {
 		m.generateIndexes()
 	}
 
-	for _, index := range m.indexes {
+	for i := 0; i < len(m.indexes); i++ {
+		index := m.indexes[i]
 		if index < 3 { // second, third bitmap
 			continue
 		}
@@ -206,6 +213,10 @@ func (m *isoMessage) Load(raw []byte) (int, error) {
 			return 0, err
 		}
 		start += read
+		if index == tertiaryBitmapIndex {
+			// fields above 128 follow the tertiary bitmap
+			m.generateIndexes()
+		}
 	}
 
 	if start != len(raw) {
@@ -233,6 +244,141 @@ func (m *isoMessage) GetBitmap() *Element {
 	return m.bitmap
 }
 
+// SetMTI set mti of iso message
+func (m *isoMessage) SetMTI(mti string) error {
+	if len(mti) != m.mti.Length {
+		return errors.New(utils.ErrInvalidElementLength)
+	}
+	if !utils.RegexNumeric(mti) {
+		return errors.New(utils.ErrBadElementData)
+	}
+	m.mti.Value = []byte(mti)
+	return nil
+}
+
+// SetField set value of data element typed by the specification, bitmaps
+// are updated with the element
+func (m *isoMessage) SetField(index int, value string) error {
+	if err := m.checkFieldIndex(index); err != nil {
+		return err
+	}
+	elm, err := m.newElement(index)
+	if err != nil {
+		return err
+	}
+	if err := setElementValue(elm, value); err != nil {
+		return err
+	}
+
+	m.elements.elements[index] = elm
+	return m.updateBitmaps()
+}
+
+// GetSubfield return value of element or subfield of composite element
+// addressed like 48.2 or 90.1
+func (m *isoMessage) GetSubfield(path string) (string, error) {
+	indexes, err := parsePath(path)
+	if err != nil {
+		return "", err
+	}
+	elm, exist := m.elements.elements[indexes[0]]
+	if !exist || elm == nil {
+		return "", errors.New(utils.ErrNonExistElement)
+	}
+	if len(indexes) > 1 {
+		elm, err = elm.Subfield(path[strings.Index(path, ".")+1:])
+		if err != nil {
+			return "", err
+		}
+	}
+	return elm.String(), nil
+}
+
+// SetSubfield set value of element or subfield of composite element
+// addressed like 48.2 or 90.1, composite elements of the path are created
+// when they don't exist
+func (m *isoMessage) SetSubfield(path string, value string) error {
+	indexes, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	if len(indexes) == 1 {
+		return m.SetField(indexes[0], value)
+	}
+	if err := m.checkFieldIndex(indexes[0]); err != nil {
+		return err
+	}
+
+	elm := m.elements.elements[indexes[0]]
+	created := elm == nil
+	if created {
+		if elm, err = m.newElement(indexes[0]); err != nil {
+			return err
+		}
+	}
+	// elements of the path are attached when the value is valid
+	chain := []*Element{elm}
+	for i, index := range indexes[1:] {
+		parent := chain[i]
+		if !parent.isComposite() {
+			return errors.New(utils.ErrNonExistSpecification)
+		}
+		if parent.SubfieldBitmap > 0 && index > parent.SubfieldBitmap {
+			return errors.New(utils.ErrInvalidElementIndex)
+		}
+		sub := parent.Subfields[index]
+		if sub == nil || i == len(indexes)-2 {
+			if sub, err = parent.newSubfield(index); err != nil {
+				return err
+			}
+		}
+		chain = append(chain, sub)
+	}
+	if err := setElementValue(chain[len(chain)-1], value); err != nil {
+		return err
+	}
+
+	for i := len(chain) - 1; i > 0; i-- {
+		parent := chain[i-1]
+		if parent.Subfields == nil {
+			parent.Subfields = make(map[int]*Element)
+		}
+		parent.Subfields[indexes[i]] = chain[i]
+	}
+	if created {
+		m.elements.elements[indexes[0]] = elm
+		return m.updateBitmaps()
+	}
+	return nil
+}
+
+// GetField return value of data element
+func (m *isoMessage) GetField(index int) (string, error) {
+	elm, exist := m.elements.elements[index]
+	if !exist || elm == nil {
+		return "", errors.New(utils.ErrNonExistElement)
+	}
+	return elm.String(), nil
+}
+
+// UnsetField remove data element, bitmaps are updated without the element
+func (m *isoMessage) UnsetField(index int) error {
+	if err := m.checkFieldIndex(index); err != nil {
+		return err
+	}
+	if _, exist := m.elements.elements[index]; !exist {
+		return nil
+	}
+	delete(m.elements.elements, index)
+	return m.updateBitmaps()
+}
+
+// HasField return existence of data element
+func (m *isoMessage) HasField(index int) bool {
+	elm, exist := m.elements.elements[index]
+	return exist && elm != nil
+}
+
 // Customize unmarshal of json
 func (m *isoMessage) UnmarshalJSON(b []byte) error {
 	dummy := messageJSON{
@@ -290,15 +436,30 @@ func (m *isoMessage) MarshalXML(encoder *xml.Encoder, start xml.StartElement) er
 }
 
 // private functions ...
+
+// indexes of bitmap elements, bit 1 and bit 65 indicate them
+const (
+	secondBitmapIndex   = 1
+	tertiaryBitmapIndex = 65
+)
+
+func isBitmapIndex(index int) bool {
+	return index == secondBitmapIndex || index == tertiaryBitmapIndex
+}
+
 func (m *isoMessage) generateIndexes() {
 	if m.bitmap == nil {
 		return
 	}
 	m.indexes = utils.BitmapToIndexArray(m.bitmap.String(), 0)
 	if utils.IsSecondBitmap(m.bitmap.String()) {
-		if m.elements.elements[1] != nil {
-			indexes := utils.BitmapToIndexArray(m.elements.elements[1].String(), 64)
+		if second := m.elements.elements[secondBitmapIndex]; second != nil {
+			indexes := utils.BitmapToIndexArray(second.String(), 64)
 			m.indexes = append(m.indexes, indexes...)
+			if tertiary := m.elements.elements[tertiaryBitmapIndex]; utils.IsSecondBitmap(second.String()) && isBitmapElement(tertiary) {
+				indexes := utils.BitmapToIndexArray(tertiary.String(), 128)
+				m.indexes = append(m.indexes, indexes...)
+			}
 		}
 		if utils.IsThirdBitmap(m.bitmap.String()) {
 			if m.elements.elements[2] != nil &&
@@ -311,18 +472,85 @@ func (m *isoMessage) generateIndexes() {
 	}
 }
 
-func (m *isoMessage) createElement(index, start int, raw []byte) (int, error) {
+// updateBitmaps set bitmaps from indexes of data elements. The second
+// bitmap is element 1 and the tertiary bitmap is element 65, they are
+// indicated by bit 1 and bit 65
+func (m *isoMessage) updateBitmaps() error {
+	bitmaps := make([]byte, 192)
+	for i := range bitmaps {
+		bitmaps[i] = '0'
+	}
+	tertiary := m.hasTertiaryBitmap()
+	for index := range m.elements.elements {
+		if index == secondBitmapIndex || (index == tertiaryBitmapIndex && tertiary) {
+			continue
+		}
+		bitmaps[index-1] = '1'
+	}
+
+	if bytes.IndexByte(bitmaps[128:], '1') >= 0 {
+		elm, err := m.newElement(tertiaryBitmapIndex)
+		if err != nil {
+			return err
+		}
+		elm.Value = append([]byte(nil), bitmaps[128:]...)
+		m.elements.elements[tertiaryBitmapIndex] = elm
+		bitmaps[tertiaryBitmapIndex-1] = '1'
+	} else if tertiary {
+		delete(m.elements.elements, tertiaryBitmapIndex)
+	}
+
+	if bytes.IndexByte(bitmaps[64:128], '1') >= 0 {
+		elm, err := m.newElement(secondBitmapIndex)
+		if err != nil {
+			return err
+		}
+		elm.Value = append([]byte(nil), bitmaps[64:128]...)
+		m.elements.elements[secondBitmapIndex] = elm
+		bitmaps[secondBitmapIndex-1] = '1'
+	} else {
+		delete(m.elements.elements, secondBitmapIndex)
+	}
+
+	m.bitmap.Value = bitmaps[:64]
+	m.generateIndexes()
+	return nil
+}
+
+// checkFieldIndex check index of data element set by the caller, bitmaps
+// are maintained by the message
+func (m *isoMessage) checkFieldIndex(index int) error {
+	if index <= secondBitmapIndex || index > 192 {
+		return errors.New(utils.ErrInvalidElementIndex)
+	}
+	tertiary := m.hasTertiaryBitmap()
+	if (index == tertiaryBitmapIndex && tertiary) || (index > 128 && !tertiary) {
+		return errors.New(utils.ErrInvalidElementIndex)
+	}
+	return nil
+}
+
+// hasTertiaryBitmap return whether element 65 of the specification is
+// the tertiary bitmap
+func (m *isoMessage) hasTertiaryBitmap() bool {
+	elm, err := m.newElement(tertiaryBitmapIndex)
+	return err == nil && isBitmapElement(elm)
+}
+
+// newElement create data element without value, typed by the specification
+func (m *isoMessage) newElement(index int) (*Element, error) {
 	spec, err := m.spec.Elements.Get(index)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	_type, err := spec.Parse()
+	return newDataElement(index, spec, m.spec.Encoding)
+}
+
+func (m *isoMessage) createElement(index, start int, raw []byte) (int, error) {
+	elm, err := m.newElement(index)
 	if err != nil {
 		return 0, err
 	}
-	_type.SetEncoding(m.spec.Encoding)
-	elm := &Element{}
-	elm.setType(_type)
 
 	if start >= len(raw) {
 		return 0, errors.New(utils.ErrBadRaw)
@@ -346,7 +574,8 @@ func (m *isoMessage) isValidMessageType() (*utils.MessageType, bool) {
 	return nil, false
 }
 
-func (m *isoMessage) validateMessageField(messageType *utils.MessageType) error {
+func (m *isoMessage) validateMessageField(messageType *utils.MessageType) ValidationReport {
+	var report ValidationReport
 	mandatory, _ := getBinaryFromHex(messageType.MandatoryHexMask)
 	optional, _ := getBinaryFromHex(messageType.OptionalHexMask)
 	mandatoryIndexes := utils.BitmapToIndexArray(mandatory, 0)
@@ -357,17 +586,45 @@ func (m *isoMessage) validateMessageField(messageType *utils.MessageType) error
 
 	for _, index := range m.indexes {
 		if !contains(mandatoryIndexes, index) && !contains(optionalIndexes, index) {
-			return errors.New("exist unexpected field")
+			err := ruleError(RuleUnexpectedField, errors.New(utils.ErrUnexpectedField))
+			report = append(report, newValidationError(index, m.attribute(index), m.elements.elements[index], err))
 		}
 	}
 
 	for _, index := range mandatoryIndexes {
 		if !contains(m.indexes, index) {
-			return errors.New("don't exist mandatory field")
+			err := ruleError(RuleMandatoryField, errors.New(utils.ErrMandatoryField))
+			report = append(report, newValidationError(index, m.attribute(index), nil, err))
 		}
 	}
 
-	return nil
+	return report
+}
+
+// attribute return attribute of element in the specification, nil when it
+// isn't defined
+func (m *isoMessage) attribute(index int) *utils.Attribute {
+	if m.spec.Elements == nil {
+		return nil
+	}
+	attr, _ := m.spec.Elements.Get(index)
+	return attr
+}
+
+// setElementValue set value of new element, it's checked with the type
+// and the length rules of the element
+func setElementValue(elm *Element, value string) error {
+	if err := elm.setText([]byte(value)); err != nil {
+		return err
+	}
+	return elm.Validate()
+}
+
+func isBitmapElement(elm *Element) bool {
+	if elm == nil || elm.Length != 64 {
+		return false
+	}
+	return elm.Type == utils.ElementTypeBitmap || (elm.Type == utils.ElementTypeBinary && !elm.isOpaque())
 }
 
 func contains(indexes []int, index int) bool {
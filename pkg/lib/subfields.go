@@ -0,0 +1,333 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/moov-io/iso8583/pkg/utils"
+)
+
+// Subfield return subfield of composite element by path of subfield
+// numbers, for example "2" or "2.1"
+func (e *Element) Subfield(path string) (*Element, error) {
+	indexes, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	elm := e
+	for _, index := range indexes {
+		elm = elm.Subfields[index]
+		if elm == nil {
+			return nil, errors.New(utils.ErrNonExistElement)
+		}
+	}
+	return elm, nil
+}
+
+// private functions ...
+func (e *Element) isComposite() bool {
+	return len(e.SubfieldAttributes) > 0
+}
+
+func (e *Element) subfieldKeys() []int {
+	var keys []int
+	for k := range e.Subfields {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func (e *Element) encodingDefinition() *utils.EncodingDefinition {
+	if e.definition == nil {
+		return utils.DefaultMessageEncoding
+	}
+	return e.definition
+}
+
+// newSubfield create subfield without value, typed by the specification of
+// the composite element
+func (e *Element) newSubfield(index int) (*Element, error) {
+	attr, err := e.SubfieldAttributes.Get(index)
+	if err != nil {
+		return nil, err
+	}
+	return newElement(attr, e.encodingDefinition())
+}
+
+// subfieldBitmap create bitmap of present subfields, it has encoding of
+// the message bitmap
+func (e *Element) subfieldBitmap() *Element {
+	return &Element{
+		Type:     utils.ElementTypeBitmap,
+		Length:   e.SubfieldBitmap,
+		Fixed:    true,
+		Encoding: e.encodingDefinition().BitmapEnc,
+	}
+}
+
+func (e *Element) validateSubfields() error {
+	if err := e.validateWithEncoding(); err != nil {
+		return ruleError(RuleEncoding, err)
+	}
+	for _, index := range e.subfieldKeys() {
+		if e.SubfieldBitmap > 0 && index > e.SubfieldBitmap {
+			return ruleError(RuleSubfield, errors.New(utils.ErrInvalidElementIndex))
+		}
+		if err := e.Subfields[index].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packSubfields encode subfields one after another. Subfields without
+// bitmap have fixed positions, absent ones are encoded as empty values up
+// to the last present subfield, or up to the last subfield of fixed
+// element.
+func (e *Element) packSubfields() ([]byte, error) {
+	var buf bytes.Buffer
+
+	indexes := e.subfieldKeys()
+	if e.SubfieldBitmap > 0 {
+		bits := bytes.Repeat([]byte("0"), e.SubfieldBitmap)
+		for _, index := range indexes {
+			if index < 1 || index > e.SubfieldBitmap {
+				return nil, errors.New(utils.ErrInvalidElementIndex)
+			}
+			bits[index-1] = '1'
+		}
+		bitmap := e.subfieldBitmap()
+		bitmap.Value = bits
+		value, err := bitmap.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	} else {
+		keys := e.SubfieldAttributes.Keys()
+		last := 0
+		if len(indexes) > 0 {
+			last = indexes[len(indexes)-1]
+		}
+		if e.Fixed && len(keys) > 0 {
+			last = keys[len(keys)-1]
+		}
+		indexes = nil
+		for _, index := range keys {
+			if index <= last {
+				indexes = append(indexes, index)
+			}
+		}
+	}
+
+	for _, index := range indexes {
+		sub := e.Subfields[index]
+		if sub == nil {
+			var err error
+			sub, err = e.newSubfield(index)
+			if err != nil {
+				return nil, err
+			}
+		}
+		value, err := sub.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	return buf.Bytes(), nil
+}
+
+// unpackSubfields decode subfields from content of the element. Subfields
+// without bitmap are decoded until the end of content.
+func (e *Element) unpackSubfields(content []byte) error {
+	subfields := make(map[int]*Element)
+	start := 0
+
+	indexes := e.SubfieldAttributes.Keys()
+	if e.SubfieldBitmap > 0 {
+		bitmap := e.subfieldBitmap()
+		read, err := bitmap.Load(content)
+		if err != nil {
+			return err
+		}
+		start += read
+		indexes = utils.BitmapToIndexArray(bitmap.String(), 0)
+	}
+
+	for _, index := range indexes {
+		if e.SubfieldBitmap == 0 && start >= len(content) {
+			break
+		}
+		sub, err := e.newSubfield(index)
+		if err != nil {
+			return err
+		}
+		read, err := sub.Load(content[start:])
+		if err != nil {
+			return err
+		}
+		start += read
+		subfields[index] = sub
+	}
+
+	if start != len(content) {
+		return errors.New(utils.ErrBadRaw)
+	}
+	e.Subfields = subfields
+	if !e.Fixed {
+		e.DataLength = len(content)
+	}
+	return nil
+}
+
+// packContent encode subfields of composite element or tags of dataset
+func (e *Element) packContent() ([]byte, error) {
+	if e.isDataset() {
+		return e.packDataset()
+	}
+	return e.packSubfields()
+}
+
+// unpackContent decode subfields of composite element or tags of dataset
+func (e *Element) unpackContent(content []byte) error {
+	if e.isDataset() {
+		return e.unpackDataset(content)
+	}
+	return e.unpackSubfields(content)
+}
+
+func (e *Element) compositeEncoding() ([]byte, error) {
+	content, err := e.packContent()
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > e.Length {
+		return nil, fmt.Errorf(utils.ErrValueTooLong, e.Type, e.Length, len(content))
+	}
+	if e.Fixed {
+		if len(content) != e.Length {
+			return nil, errors.New(utils.ErrInvalidElementLength)
+		}
+		return content, nil
+	}
+
+	lenEncode, err := e.lengthEncoding(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(lenEncode, content...), nil
+}
+
+func (e *Element) compositeDecoding(raw []byte) (int, error) {
+	read, err := e.lengthDecoding(raw)
+	if err != nil {
+		return 0, err
+	}
+
+	contentLen := e.Length
+	if !e.Fixed {
+		contentLen = e.DataLength
+	}
+	if len(raw) < read+contentLen {
+		return 0, errors.New(utils.ErrBadElementData)
+	}
+
+	if err := e.unpackContent(raw[read : read+contentLen]); err != nil {
+		return 0, err
+	}
+	return read + contentLen, nil
+}
+
+// subfieldsFromJSON decode subfields from json object, which was kept
+// until the element is typed
+func (e *Element) subfieldsFromJSON() error {
+	if !e.isComposite() {
+		return errors.New(utils.ErrBadElementData)
+	}
+	var raw map[int]json.RawMessage
+	if err := json.Unmarshal(e.rawJSON, &raw); err != nil {
+		return err
+	}
+	e.rawJSON = nil
+
+	e.Subfields = make(map[int]*Element)
+	for index, value := range raw {
+		sub, err := e.newSubfield(index)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(value, sub); err != nil {
+			return err
+		}
+		e.Subfields[index] = sub
+	}
+	return nil
+}
+
+// setXML set value from xml element, subfields are nested elements
+func (e *Element) setXML(element xmlElement) error {
+	if len(element.Elements) == 0 {
+		return e.setText([]byte(element.Text))
+	}
+	if !e.isComposite() {
+		return errors.New(utils.ErrBadElementData)
+	}
+
+	e.Subfields = make(map[int]*Element)
+	for _, child := range element.Elements {
+		sub, err := e.newSubfield(child.Number)
+		if err != nil {
+			return err
+		}
+		if err := sub.setXML(child); err != nil {
+			return err
+		}
+		e.Subfields[child.Number] = sub
+	}
+	return nil
+}
+
+// elementTokens return xml tokens of element, subfields are nested elements
+func elementTokens(number int, elm *Element) []xml.Token {
+	start := xml.StartElement{
+		Name: xml.Name{Local: utils.DataElementXmlName},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: utils.DataElementAttrNumber}, Value: strconv.Itoa(number)},
+		},
+	}
+	tokens := []xml.Token{start}
+	if elm.isComposite() {
+		for _, index := range elm.subfieldKeys() {
+			tokens = append(tokens, elementTokens(index, elm.Subfields[index])...)
+		}
+	} else {
+		tokens = append(tokens, xml.CharData(elm.String()))
+	}
+	return append(tokens, xml.EndElement{Name: start.Name})
+}
+
+// parsePath return numbers of path like 48.2
+func parsePath(path string) ([]int, error) {
+	var indexes []int
+	for _, number := range strings.Split(path, ".") {
+		index, err := strconv.Atoi(number)
+		if err != nil || index < 1 {
+			return nil, errors.New(utils.ErrInvalidElementIndex)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
@@ -0,0 +1,221 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/moov-io/iso8583/pkg/utils"
+)
+
+// TLV is BER-TLV data object, constructed data object has nested data
+// objects instead of value
+type TLV struct {
+	Tag      string // hex of tag bytes, for example 9F26
+	Value    []byte
+	Children []TLV
+}
+
+// json representation of data object, tags are named by EMV dictionary
+type tlvJSON struct {
+	Tag      string `json:"tag"`
+	Name     string `json:"name,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Children []TLV  `json:"children,omitempty"`
+}
+
+// Constructed return whether data object has nested data objects
+func (t TLV) Constructed() bool {
+	tag, err := hex.DecodeString(t.Tag)
+	return err == nil && len(tag) > 0 && tag[0]&0x20 != 0
+}
+
+// Name return name of the tag in EMV dictionary, empty for unknown tags
+func (t TLV) Name() string {
+	return utils.EMVTags[strings.ToUpper(t.Tag)].Name
+}
+
+// Customize marshal of json
+func (t TLV) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tlvJSON{
+		Tag:      strings.ToUpper(t.Tag),
+		Name:     t.Name(),
+		Value:    strings.ToUpper(hex.EncodeToString(t.Value)),
+		Children: t.Children,
+	})
+}
+
+// Customize unmarshal of json, name of the tag is ignored
+func (t *TLV) UnmarshalJSON(b []byte) error {
+	var dummy tlvJSON
+	if err := json.Unmarshal(b, &dummy); err != nil {
+		return err
+	}
+	value, err := hex.DecodeString(dummy.Value)
+	if err != nil {
+		return errors.New(utils.ErrBadTLV)
+	}
+	*t = TLV{Tag: strings.ToUpper(dummy.Tag), Value: value, Children: dummy.Children}
+	return nil
+}
+
+// DecodeTLV decode data objects of BER-TLV, which follow one after another
+// in raw. Multi-byte tags, constructed tags and long form lengths (up to 4
+// bytes) are supported.
+func DecodeTLV(raw []byte) ([]TLV, error) {
+	tlvs := []TLV{}
+	for start := 0; start < len(raw); {
+		tagLen, err := tlvTagLength(raw[start:])
+		if err != nil {
+			return nil, err
+		}
+		tag := raw[start : start+tagLen]
+		start += tagLen
+
+		length, read, err := tlvLengthDecoding(raw[start:])
+		if err != nil {
+			return nil, err
+		}
+		start += read
+		if length < 0 || length > len(raw)-start {
+			return nil, errors.New(utils.ErrBadTLV)
+		}
+		value := raw[start : start+length]
+		start += length
+
+		tlv := TLV{Tag: strings.ToUpper(hex.EncodeToString(tag))}
+		if tag[0]&0x20 != 0 {
+			if tlv.Children, err = DecodeTLV(value); err != nil {
+				return nil, err
+			}
+		} else {
+			tlv.Value = append([]byte{}, value...)
+		}
+		tlvs = append(tlvs, tlv)
+	}
+	return tlvs, nil
+}
+
+// EncodeTLV encode data objects of BER-TLV, lengths use the shortest form
+func EncodeTLV(tlvs []TLV) ([]byte, error) {
+	var raw []byte
+	for _, tlv := range tlvs {
+		tag, err := hex.DecodeString(tlv.Tag)
+		if err != nil {
+			return nil, errors.New(utils.ErrBadTLV)
+		}
+		if tagLen, err := tlvTagLength(tag); err != nil || tagLen != len(tag) {
+			return nil, errors.New(utils.ErrBadTLV)
+		}
+
+		value := tlv.Value
+		if tlv.Constructed() {
+			if value, err = EncodeTLV(tlv.Children); err != nil {
+				return nil, err
+			}
+		} else if len(tlv.Children) > 0 {
+			return nil, errors.New(utils.ErrBadTLV)
+		}
+
+		raw = append(raw, tag...)
+		raw = append(raw, tlvLengthEncoding(len(value))...)
+		raw = append(raw, value...)
+	}
+	return raw, nil
+}
+
+// TLVs return data objects of TLV element
+func (e *Element) TLVs() ([]TLV, error) {
+	if !e.TLV {
+		return nil, errors.New(utils.ErrInvalidElementType)
+	}
+	return DecodeTLV(e.Value)
+}
+
+// SetTLVs set data objects as value of TLV element
+func (e *Element) SetTLVs(tlvs []TLV) error {
+	if !e.TLV {
+		return errors.New(utils.ErrInvalidElementType)
+	}
+	raw, err := EncodeTLV(tlvs)
+	if err != nil {
+		return err
+	}
+	e.Value = raw
+	e.DataLength = len(raw)
+	return nil
+}
+
+// private functions ...
+func (e *Element) validateTLV() error {
+	if !e.isOpaque() {
+		return ruleError(RuleEncoding, errors.New(utils.ErrNonAvailableEncoding))
+	}
+	_, err := DecodeTLV(e.Value)
+	return ruleError(RuleTLV, err)
+}
+
+// tlvsFromJSON decode data objects from json array, which was kept until
+// the element is typed
+func (e *Element) tlvsFromJSON() error {
+	var tlvs []TLV
+	if err := json.Unmarshal(e.rawJSON, &tlvs); err != nil {
+		return err
+	}
+	e.rawJSON = nil
+	return e.SetTLVs(tlvs)
+}
+
+// tlvTagLength return number of tag bytes, subsequent bytes follow when
+// bits 1-5 of the first byte are set and while bit 8 is set
+func tlvTagLength(raw []byte) (int, error) {
+	if len(raw) == 0 {
+		return 0, errors.New(utils.ErrBadTLV)
+	}
+	if raw[0]&0x1F != 0x1F {
+		return 1, nil
+	}
+	for i := 1; i < len(raw); i++ {
+		if raw[i]&0x80 == 0 {
+			return i + 1, nil
+		}
+	}
+	return 0, errors.New(utils.ErrBadTLV)
+}
+
+// tlvLengthDecoding return length of value and number of length bytes,
+// long form has number of subsequent length bytes in bits 1-7
+func tlvLengthDecoding(raw []byte) (int, int, error) {
+	if len(raw) == 0 {
+		return 0, 0, errors.New(utils.ErrBadTLV)
+	}
+	if raw[0]&0x80 == 0 {
+		return int(raw[0]), 1, nil
+	}
+	size := int(raw[0] & 0x7F)
+	// indefinite length isn't supported
+	if size == 0 || size > 4 || len(raw) < size+1 {
+		return 0, 0, errors.New(utils.ErrBadTLV)
+	}
+	length := 0
+	for _, b := range raw[1 : size+1] {
+		length = length<<8 | int(b)
+	}
+	return length, size + 1, nil
+}
+
+func tlvLengthEncoding(length int) []byte {
+	if length < 0x80 {
+		return []byte{byte(length)}
+	}
+	var buf []byte
+	for ; length > 0; length >>= 8 {
+		buf = append([]byte{byte(length)}, buf...)
+	}
+	return append([]byte{0x80 | byte(len(buf))}, buf...)
+}
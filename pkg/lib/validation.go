@@ -0,0 +1,123 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/moov-io/iso8583/pkg/utils"
+)
+
+// names of validation rules
+const (
+	RuleEncoding        = "encoding"         // available encoding of the element
+	RuleType            = "type"             // characters of the element type
+	RuleFormat          = "format"           // date and time format
+	RuleLength          = "length"           // length of the value
+	RuleTLV             = "tlv"              // BER-TLV data objects
+	RuleSubfield        = "subfield"         // subfields of composite element
+	RuleDataset         = "dataset"          // tags of dataset
+	RuleBitmap          = "bitmap"           // bitmap matching data elements
+	RuleUnexpectedField = "unexpected_field" // element isn't defined by the message type
+	RuleMandatoryField  = "mandatory_field"  // mandatory element of the message type
+)
+
+// RuleError is error of violated validation rule, it keeps text of the
+// error
+type RuleError struct {
+	Rule string
+	Err  error
+}
+
+func (e *RuleError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is violated rule of message, Field is 0 for MTI and
+// bitmap. Value of sensitive element is masked.
+type ValidationError struct {
+	Field       int    `json:"field"`
+	Description string `json:"description,omitempty"`
+	Value       string `json:"value,omitempty"`
+	Rule        string `json:"rule"`
+	Err         error  `json:"-"`
+}
+
+func (e *ValidationError) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("field %d: %s: %v", e.Field, e.Rule, e.Err)
+	}
+	return fmt.Sprintf("field %d (%s): %s: %v", e.Field, e.Description, e.Rule, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Customize marshal of json, error is its text
+func (e *ValidationError) MarshalJSON() ([]byte, error) {
+	type alias ValidationError
+	return json.Marshal(struct {
+		*alias
+		Error string `json:"error"`
+	}{(*alias)(e), e.Err.Error()})
+}
+
+// ValidationReport is multi-error of all violated rules of message
+type ValidationReport []*ValidationError
+
+func (r ValidationReport) Error() string {
+	texts := make([]string, 0, len(r))
+	for _, e := range r {
+		texts = append(texts, e.Error())
+	}
+	return strings.Join(texts, "; ")
+}
+
+// private functions ...
+
+// err return nil for empty report
+func (r ValidationReport) err() error {
+	if len(r) == 0 {
+		return nil
+	}
+	return r
+}
+
+// ruleError attach rule to error, errors with rules keep them
+func ruleError(rule string, err error) error {
+	var ruleErr *RuleError
+	if err == nil || errors.As(err, &ruleErr) {
+		return err
+	}
+	return &RuleError{Rule: rule, Err: err}
+}
+
+// newValidationError create error of element with attribute of the
+// specification, attr may be nil
+func newValidationError(index int, attr *utils.Attribute, elm *Element, err error) *ValidationError {
+	verr := &ValidationError{Field: index, Err: err, Rule: RuleType}
+	var ruleErr *RuleError
+	if errors.As(err, &ruleErr) {
+		verr.Rule, verr.Err = ruleErr.Rule, ruleErr.Err
+	}
+	if attr != nil {
+		verr.Description = attr.Description
+	}
+	if elm != nil {
+		verr.Value = elm.String()
+		if utils.SensitiveElements[index] || (attr != nil && attr.Sensitive) {
+			verr.Value = utils.MaskValue(index, verr.Value)
+		}
+	}
+	return verr
+}
@@ -25,6 +25,15 @@ func outputError(w http.ResponseWriter, code int, err error) {
 	})
 }
 
+func outputValidationError(w http.ResponseWriter, code int, report lib.ValidationReport) {
+	w.WriteHeader(code)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  report.Error(),
+		"errors": report,
+	})
+}
+
 func outputSuccess(w http.ResponseWriter, output string) {
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -123,7 +132,11 @@ func validator(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = message.Validate()
-	if err != nil {
+	var report lib.ValidationReport
+	if errors.As(err, &report) {
+		outputValidationError(w, http.StatusNotImplemented, report)
+		return
+	} else if err != nil {
 		outputError(w, http.StatusNotImplemented, err)
 		return
 	}
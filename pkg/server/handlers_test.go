@@ -242,6 +242,7 @@ func (suite *HandlersTest) TestValidatorWithInvalidData() {
 	request.Header.Set("Content-Type", writer.FormDataContentType())
 	suite.testServer.ServeHTTP(recorder, request)
 	assert.Equal(suite.T(), http.StatusNotImplemented, recorder.Code)
+	assert.Contains(suite.T(), recorder.Body.String(), `"errors":[{"field":13,"description":"Local transaction date (MMDD)","value":"9099","rule":"format","error":"bad element data"}]`)
 }
 
 func (suite *HandlersTest) TestPrintWithErrorData() {
@@ -31,6 +31,19 @@ const (
 	EncodingAscii  = "ASCII" // for characters
 	EncodingBcd    = "BCD"   // packed bcd
 	EncodingRBcd   = "RBCD"
+	// for binary data as bytes, instead of bit characters
+	EncodingBinary    = "BINARY"    // raw bytes, bitmaps are packed in bytes, lengths are big-endian
+	EncodingHexBinary = "HEXBINARY" // hex characters of bytes
+
+	// for binary data in json and xml
+	EncodingBase64 = "BASE64"
+
+	JustifyLeft  = "LEFT"
+	JustifyRight = "RIGHT"
+
+	// handling of unknown tags of dataset
+	UnknownTagsPass   = "PASS"
+	UnknownTagsReject = "REJECT"
 
 	EncodingCatNumber    = "number"
 	EncodingCatBinary    = "binary"
@@ -75,19 +88,19 @@ var ElementDataTypes = []string{
 // data representation attributes
 var AvailableEncodings = map[string][]string{
 	ElementTypeMti:                 {EncodingChar, EncodingBcd},
-	ElementTypeBitmap:              {EncodingChar, EncodingHex},
+	ElementTypeBitmap:              {EncodingChar, EncodingHex, EncodingBinary},
 	ElementTypeAlphabetic:          {EncodingAscii, EncodingEbcdic},
 	ElementTypeNumeric:             {EncodingBcd, EncodingRBcd, EncodingChar},
 	ElementTypeSpecial:             {EncodingAscii, EncodingEbcdic},
 	ElementTypeMagnetic:            {EncodingEbcdic},
 	ElementTypeIndicate:            {EncodingAscii, EncodingEbcdic},
-	ElementTypeBinary:              {EncodingChar, EncodingHex},
+	ElementTypeBinary:              {EncodingChar, EncodingHex, EncodingBinary, EncodingHexBinary},
 	ElementTypeAlphaNumeric:        {EncodingAscii, EncodingEbcdic},
 	ElementTypeAlphaSpecial:        {EncodingAscii, EncodingEbcdic},
 	ElementTypeNumericSpecial:      {EncodingAscii, EncodingEbcdic},
 	ElementTypeAlphaNumericSpecial: {EncodingAscii, EncodingEbcdic},
 	ElementTypeIndicateNumeric:     {EncodingAscii, EncodingEbcdic},
-	ElementTypeNumberEncoding:      {EncodingChar, EncodingHex, EncodingRBcd, EncodingBcd},
+	ElementTypeNumberEncoding:      {EncodingChar, EncodingHex, EncodingRBcd, EncodingBcd, EncodingBinary},
 }
 
 var AvailableTypeCategory = map[string]string{
@@ -114,6 +127,11 @@ var AvailableDateFormat = map[string]func(s string) bool{
 	"MMDDHHMMSS": RegexDateMMDDHHMMSS,
 }
 
+// IsOpaqueBinary return whether binary data has encoding of bytes
+func IsOpaqueBinary(eType string, encoding string) bool {
+	return eType == ElementTypeBinary && (encoding == EncodingBinary || encoding == EncodingHexBinary)
+}
+
 func CheckAvailableEncoding(eType string, encoding string) bool {
 	encodings, exit := AvailableEncodings[eType]
 	if !exit {
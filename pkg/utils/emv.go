@@ -0,0 +1,90 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package utils
+
+// TagDefinition describe data object of EMV, Format is the data format of
+// EMV specification (a, an, ans, b, cn or n)
+type TagDefinition struct {
+	Name   string
+	Format string
+}
+
+var (
+	// EMVTags is dictionary of EMV data objects by hex tag
+	EMVTags = map[string]TagDefinition{
+		"42":   {Name: "Issuer Identification Number (IIN)", Format: "n"},
+		"4F":   {Name: "Application Identifier (AID) - card", Format: "b"},
+		"50":   {Name: "Application Label", Format: "ans"},
+		"57":   {Name: "Track 2 Equivalent Data", Format: "b"},
+		"5A":   {Name: "Application Primary Account Number (PAN)", Format: "cn"},
+		"5F20": {Name: "Cardholder Name", Format: "ans"},
+		"5F24": {Name: "Application Expiration Date", Format: "n"},
+		"5F25": {Name: "Application Effective Date", Format: "n"},
+		"5F28": {Name: "Issuer Country Code", Format: "n"},
+		"5F2A": {Name: "Transaction Currency Code", Format: "n"},
+		"5F2D": {Name: "Language Preference", Format: "an"},
+		"5F30": {Name: "Service Code", Format: "n"},
+		"5F34": {Name: "Application Primary Account Number (PAN) Sequence Number", Format: "n"},
+		"5F36": {Name: "Transaction Currency Exponent", Format: "n"},
+		"61":   {Name: "Application Template", Format: "b"},
+		"6F":   {Name: "File Control Information (FCI) Template", Format: "b"},
+		"70":   {Name: "READ RECORD Response Message Template", Format: "b"},
+		"71":   {Name: "Issuer Script Template 1", Format: "b"},
+		"72":   {Name: "Issuer Script Template 2", Format: "b"},
+		"77":   {Name: "Response Message Template Format 2", Format: "b"},
+		"80":   {Name: "Response Message Template Format 1", Format: "b"},
+		"82":   {Name: "Application Interchange Profile", Format: "b"},
+		"84":   {Name: "Dedicated File (DF) Name", Format: "b"},
+		"86":   {Name: "Issuer Script Command", Format: "b"},
+		"87":   {Name: "Application Priority Indicator", Format: "b"},
+		"88":   {Name: "Short File Identifier (SFI)", Format: "b"},
+		"89":   {Name: "Authorisation Code", Format: "an"},
+		"8A":   {Name: "Authorisation Response Code", Format: "an"},
+		"8C":   {Name: "Card Risk Management Data Object List 1 (CDOL1)", Format: "b"},
+		"8D":   {Name: "Card Risk Management Data Object List 2 (CDOL2)", Format: "b"},
+		"8E":   {Name: "Cardholder Verification Method (CVM) List", Format: "b"},
+		"8F":   {Name: "Certification Authority Public Key Index", Format: "b"},
+		"91":   {Name: "Issuer Authentication Data", Format: "b"},
+		"94":   {Name: "Application File Locator (AFL)", Format: "b"},
+		"95":   {Name: "Terminal Verification Results", Format: "b"},
+		"9A":   {Name: "Transaction Date", Format: "n"},
+		"9B":   {Name: "Transaction Status Information", Format: "b"},
+		"9C":   {Name: "Transaction Type", Format: "n"},
+		"9F01": {Name: "Acquirer Identifier", Format: "n"},
+		"9F02": {Name: "Amount, Authorised (Numeric)", Format: "n"},
+		"9F03": {Name: "Amount, Other (Numeric)", Format: "n"},
+		"9F06": {Name: "Application Identifier (AID) - terminal", Format: "b"},
+		"9F07": {Name: "Application Usage Control", Format: "b"},
+		"9F09": {Name: "Application Version Number", Format: "b"},
+		"9F0D": {Name: "Issuer Action Code - Default", Format: "b"},
+		"9F0E": {Name: "Issuer Action Code - Denial", Format: "b"},
+		"9F0F": {Name: "Issuer Action Code - Online", Format: "b"},
+		"9F10": {Name: "Issuer Application Data", Format: "b"},
+		"9F11": {Name: "Issuer Code Table Index", Format: "n"},
+		"9F12": {Name: "Application Preferred Name", Format: "ans"},
+		"9F15": {Name: "Merchant Category Code", Format: "n"},
+		"9F16": {Name: "Merchant Identifier", Format: "ans"},
+		"9F1A": {Name: "Terminal Country Code", Format: "n"},
+		"9F1C": {Name: "Terminal Identification", Format: "an"},
+		"9F1E": {Name: "Interface Device (IFD) Serial Number", Format: "an"},
+		"9F21": {Name: "Transaction Time", Format: "n"},
+		"9F26": {Name: "Application Cryptogram", Format: "b"},
+		"9F27": {Name: "Cryptogram Information Data", Format: "b"},
+		"9F33": {Name: "Terminal Capabilities", Format: "b"},
+		"9F34": {Name: "Cardholder Verification Method (CVM) Results", Format: "b"},
+		"9F35": {Name: "Terminal Type", Format: "n"},
+		"9F36": {Name: "Application Transaction Counter (ATC)", Format: "b"},
+		"9F37": {Name: "Unpredictable Number", Format: "b"},
+		"9F39": {Name: "Point-of-Service (POS) Entry Mode", Format: "n"},
+		"9F40": {Name: "Additional Terminal Capabilities", Format: "b"},
+		"9F41": {Name: "Transaction Sequence Counter", Format: "n"},
+		"9F42": {Name: "Application Currency Code", Format: "n"},
+		"9F53": {Name: "Transaction Category Code", Format: "an"},
+		"9F6E": {Name: "Form Factor Indicator", Format: "b"},
+		"9F7C": {Name: "Customer Exclusive Data", Format: "b"},
+		"A5":   {Name: "File Control Information (FCI) Proprietary Template", Format: "b"},
+		"BF0C": {Name: "File Control Information (FCI) Issuer Discretionary Data", Format: "b"},
+	}
+)
@@ -31,6 +31,28 @@ const (
 	ErrInvalidElementType string = "invalid element type"
 	// ErrMisMatchElementsBitmap is given when mismatch between bitmap and data elements
 	ErrMisMatchElementsBitmap string = "don't match bitmap and data elements"
+	// ErrInvalidPadding is given when the padding or justification of the element is invalid
+	ErrInvalidPadding string = "invalid padding"
+	// ErrInvalidElementIndex is given when the index of the element is reserved or out of range
+	ErrInvalidElementIndex string = "invalid element index"
+	// ErrNonExistElement is given when the element doesn't exist in the message
+	ErrNonExistElement string = "don't exist data element"
+	// ErrBadTLV is given when BER-TLV data objects are malformed
+	ErrBadTLV string = "bad tlv data"
+	// ErrInvalidDataset is given when the dataset of the element is invalid
+	ErrInvalidDataset string = "invalid dataset"
+	// ErrBitmapDataset is given when the dataset has bitmap body, which isn't supported
+	ErrBitmapDataset string = "bitmap dataset isn't supported"
+	// ErrUnknownTag is given when the tag of dataset isn't known and unknown tags are rejected
+	ErrUnknownTag string = "unknown dataset tag"
+	// ErrUnexpectedField is given when the element isn't defined by the message type
+	ErrUnexpectedField string = "exist unexpected field"
+	// ErrMandatoryField is given when the mandatory element of the message type doesn't exist
+	ErrMandatoryField string = "don't exist mandatory field"
+	// ErrInvalidConstraint is given when the constraints of the element are invalid
+	ErrInvalidConstraint string = "invalid constraint"
+	// ErrViolatedConstraint is given when the value violates constraint of the element
+	ErrViolatedConstraint string = "violated constraint"
 	// ErrNonInitializedMessage is given when message instance is not initialized
 	ErrNonInitializedMessage string = "non initialized message"
 )
@@ -115,6 +115,15 @@ func IsThirdBitmap(bitmap string) bool {
 	return false
 }
 
+// MaskValue masks value of sensitive data element, the first 6 and the last
+// 4 digits of primary account number (element 2) stay visible
+func MaskValue(index int, value string) string {
+	if index == 2 && len(value) > 10 {
+		return value[:6] + strings.Repeat("*", len(value)-10) + value[len(value)-4:]
+	}
+	return strings.Repeat("*", len(value))
+}
+
 // Get message format
 func MessageFormat(buf []byte) string {
 	if isValidJSON(buf) {
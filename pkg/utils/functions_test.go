@@ -174,3 +174,22 @@ func TestMessageFormat(t *testing.T) {
 	format = MessageFormat(buf)
 	assert.Equal(t, format, MessageFormatJson)
 }
+
+func TestMessageTypeFields(t *testing.T) {
+	mandatory, optional, err := MessageType{
+		MandatoryHexMask: "7230",
+		OptionalHexMask:  "000C0000000000008000000000000000",
+	}.Fields()
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2, 3, 4, 7, 11, 12}, mandatory)
+	assert.Equal(t, []int{13, 14, 65}, optional)
+
+	_, _, err = MessageType{MandatoryHexMask: "72X"}.Fields()
+	assert.EqualError(t, err, ErrInvalidBitmapArray)
+}
+
+func TestMaskValue(t *testing.T) {
+	assert.Equal(t, "411111******1111", MaskValue(2, "4111111111111111"))
+	assert.Equal(t, "**********", MaskValue(2, "4111111111"))
+	assert.Equal(t, "****", MaskValue(14, "2512"))
+}
@@ -5,8 +5,10 @@
 package utils
 
 import (
+	"encoding/hex"
 	"errors"
 	"math"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -29,6 +31,108 @@ var (
 type Attribute struct {
 	Describe    string // [attribute(b 64, b-64, b..64)]; [format(MMDD, hhmmss)]
 	Description string
+	// LengthPrefix is the width of length head of variable element (L, LL,
+	// LLL or LLLL), digits of the maximum length when empty. With BINARY
+	// length encoding it's the number of bytes (L, LL or LLLL).
+	LengthPrefix string `json:",omitempty"`
+	// Encoding and LengthEncoding override encodings of the specification
+	// for the element
+	Encoding       string `json:",omitempty"`
+	LengthEncoding string `json:",omitempty"`
+	// Padding is the character filling fixed element, Justify is LEFT or
+	// RIGHT. Characters are padded with spaces and left justified, numbers
+	// are padded with zeros and right justified by default.
+	Padding string `json:",omitempty"`
+	Justify string `json:",omitempty"`
+	// Subfields of composite element, they are encoded one after another in
+	// the value of the element. SubfieldBitmap is the number of bits (up to
+	// 64) of the bitmap preceding subfields, which are present when their
+	// bits are set.
+	Subfields      Attributes `json:",omitempty"`
+	SubfieldBitmap int        `json:",omitempty"`
+	// TLV states that the value of binary element is a list of BER-TLV
+	// data objects, like EMV data of element 55
+	TLV bool `json:",omitempty"`
+	// Dataset states that the value of character element is a list of
+	// private tag-length-value subfields
+	Dataset *Dataset `json:",omitempty"`
+	// Sensitive states that the value is masked in validation reports,
+	// elements of SensitiveElements are always masked
+	Sensitive bool `json:",omitempty"`
+	// Constraints of the value are checked by validation of the element
+	Constraints
+}
+
+// Constraints are declarative rules of value, empty ones aren't checked
+type Constraints struct {
+	// Values are allowed values, like response codes of element 39
+	Values []string `json:",omitempty"`
+	// Min and Max are bounds of numeric value
+	Min *int64 `json:",omitempty"`
+	Max *int64 `json:",omitempty"`
+	// MinLength is minimum number of characters
+	MinLength int `json:",omitempty"`
+	// Regex must match the whole value
+	Regex string `json:",omitempty"`
+	// Luhn checks check digit of primary account number
+	Luhn bool `json:",omitempty"`
+}
+
+// validate check bounds and regular expression of constraints
+func (c Constraints) validate() error {
+	if c.MinLength < 0 || (c.Min != nil && c.Max != nil && *c.Min > *c.Max) {
+		return errors.New(ErrInvalidConstraint)
+	}
+	if c.Regex != "" {
+		if _, err := regexp.Compile(c.Regex); err != nil {
+			return errors.New(ErrInvalidConstraint)
+		}
+	}
+	return nil
+}
+
+// Dataset describes private tag-length-value subfields, like additional
+// data of element 48. Tags have TagWidth characters, lengths have
+// LengthWidth digits (bytes with BINARY encoding) and they are encoded with
+// LengthEncoding, the length encoding of the specification when it's empty.
+type Dataset struct {
+	TagWidth       int
+	LengthWidth    int
+	LengthEncoding string `json:",omitempty"`
+	// Bitmap is the number of bits of a bitmap preceding the tags. Datasets
+	// with bitmap body aren't supported and they are rejected, elements
+	// with bitmap of subfields are declared with SubfieldBitmap instead.
+	Bitmap int `json:",omitempty"`
+	// Tags are known tags, their values are characters validated by the
+	// attributes
+	Tags map[string]Attribute `json:",omitempty"`
+	// Unknown is handling of tags missing in Tags, PASS (default) or REJECT
+	Unknown string `json:",omitempty"`
+}
+
+// validate check dataset of element with type eType
+func (d *Dataset) validate(eType string) error {
+	if AvailableTypeCategory[eType] != EncodingCatCharacter {
+		return errors.New(ErrInvalidElementType)
+	}
+	if d.Bitmap != 0 {
+		return errors.New(ErrBitmapDataset)
+	}
+	if d.TagWidth < 1 || d.LengthWidth < 1 || d.LengthWidth > 4 {
+		return errors.New(ErrInvalidDataset)
+	}
+	if d.Unknown != "" && d.Unknown != UnknownTagsPass && d.Unknown != UnknownTagsReject {
+		return errors.New(ErrInvalidDataset)
+	}
+	for tag, attr := range d.Tags {
+		if len(tag) != d.TagWidth || attr.Dataset != nil || len(attr.Subfields) > 0 || attr.TLV {
+			return errors.New(ErrInvalidDataset)
+		}
+		if _, err := attr.Parse(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Parse return ElementType from attribute string
@@ -51,11 +155,45 @@ func (s Attribute) Parse() (*ElementType, error) {
 				if err != nil || (!isFixed && _size > int(math.Pow(10, float64(len(indicate))))) {
 					return nil, errors.New(ErrInvalidElementLength)
 				}
+				lengthSize, err := s.lengthSize(isFixed)
+				if err != nil {
+					return nil, err
+				}
+				if len(s.Padding) > 1 || (s.Justify != "" && s.Justify != JustifyLeft && s.Justify != JustifyRight) {
+					return nil, errors.New(ErrInvalidPadding)
+				}
+				if s.SubfieldBitmap < 0 || s.SubfieldBitmap > 64 || s.SubfieldBitmap%8 != 0 || (s.SubfieldBitmap > 0 && len(s.Subfields) == 0) {
+					return nil, errors.New(ErrInvalidBitmapArray)
+				}
+				if s.TLV && (strings.TrimSpace(splits[0]) != ElementTypeBinary || len(s.Subfields) > 0) {
+					return nil, errors.New(ErrInvalidElementType)
+				}
+				if err := s.Constraints.validate(); err != nil {
+					return nil, err
+				}
+				if s.Dataset != nil {
+					if len(s.Subfields) > 0 || s.TLV {
+						return nil, errors.New(ErrInvalidDataset)
+					}
+					if err := s.Dataset.validate(strings.TrimSpace(splits[0])); err != nil {
+						return nil, err
+					}
+				}
 				return &ElementType{
-					Type:   strings.TrimSpace(splits[0]),
-					Length: _size,
-					Fixed:  isFixed,
-					Format: format,
+					Type:           strings.TrimSpace(splits[0]),
+					Length:         _size,
+					Fixed:          isFixed,
+					Format:         format,
+					LengthSize:     lengthSize,
+					Padding:        s.Padding,
+					Justify:        s.Justify,
+					Subfields:      s.Subfields,
+					SubfieldBitmap: s.SubfieldBitmap,
+					TLV:            s.TLV,
+					Dataset:        s.Dataset,
+					Constraints:    s.Constraints,
+					encoding:       s.Encoding,
+					lengthEncoding: s.LengthEncoding,
 				}, nil
 			}
 		}
@@ -64,6 +202,27 @@ func (s Attribute) Parse() (*ElementType, error) {
 	return nil, errors.New(ErrInvalidElementType)
 }
 
+// lengthSize return width of length head stated by LengthPrefix
+func (s Attribute) lengthSize(fixed bool) (int, error) {
+	if s.LengthPrefix == "" {
+		return 0, nil
+	}
+	size := len(s.LengthPrefix)
+	if fixed || size > 4 || strings.Trim(s.LengthPrefix, "L") != "" {
+		return 0, errors.New(ErrInvalidLengthHead)
+	}
+	if s.LengthEncoding == EncodingBinary && !validBinaryLengthSize(size) {
+		return 0, errors.New(ErrInvalidLengthHead)
+	}
+	return size, nil
+}
+
+// validBinaryLengthSize return whether binary length head has 1, 2 or 4
+// bytes
+func validBinaryLengthSize(size int) bool {
+	return size == 1 || size == 2 || size == 4
+}
+
 type Specification struct {
 	Elements     *Attributes         `json:"elements,omitempty"`
 	Encoding     *EncodingDefinition `json:"encoding,omitempty"`
@@ -75,6 +234,35 @@ type MessageType struct {
 	OptionalHexMask  string `json:"optional_hex_mask,omitempty"`
 }
 
+// Fields returns indexes of mandatory and optional fields of the message type
+func (t MessageType) Fields() (mandatory, optional []int, err error) {
+	mandatory, err = hexMaskIndexes(t.MandatoryHexMask)
+	if err != nil {
+		return nil, nil, err
+	}
+	optional, err = hexMaskIndexes(t.OptionalHexMask)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mandatory, optional, nil
+}
+
+func hexMaskIndexes(mask string) ([]int, error) {
+	raw, err := hex.DecodeString(mask)
+	if err != nil {
+		return nil, errors.New(ErrInvalidBitmapArray)
+	}
+	var indexes []int
+	for i, b := range raw {
+		for bit := 0; bit < 8; bit++ {
+			if b&(0x80>>uint(bit)) != 0 {
+				indexes = append(indexes, i*8+bit+1)
+			}
+		}
+	}
+	return indexes, nil
+}
+
 type Attributes map[int]Attribute
 type MessageTypes map[string]MessageType
 
@@ -102,6 +290,9 @@ type EncodingDefinition struct {
 	CharacterEnc string `json:"chr_enc"`
 	BinaryEnc    string `json:"bin_enc"`
 	TrackEnc     string `json:"trk_enc"`
+	// BinaryTextEnc is HEX or BASE64 encoding of opaque binary data in
+	// json and xml, HEX when empty
+	BinaryTextEnc string `json:"bin_txt_enc,omitempty"`
 }
 
 // general element type for all of the data representation attributes
@@ -112,15 +303,36 @@ type ElementType struct {
 	Encoding       string
 	Fixed          bool
 	LengthEncoding string
+	LengthSize     int // width of length head, 0 when it's given by Length
+	TextEncoding   string
+	Padding        string
+	Justify        string
+	Subfields      Attributes
+	SubfieldBitmap int
+	TLV            bool
+	Dataset        *Dataset
+	Constraints    Constraints
+
+	// encodings of the attribute, they override encodings of specification
+	encoding       string
+	lengthEncoding string
 }
 
+// Validate check the element type with its encodings, binary length head
+// stated by LengthPrefix must have 1, 2 or 4 bytes
 func (t *ElementType) Validate() error {
+	if t.LengthSize > 0 && t.LengthEncoding == EncodingBinary && !validBinaryLengthSize(t.LengthSize) {
+		return errors.New(ErrInvalidLengthHead)
+	}
 	return nil
 }
 
-// SetEncoding will set encoders
+// SetEncoding will set encoders, encodings of the attribute take precedence
 func (t *ElementType) SetEncoding(encoding *EncodingDefinition) {
 	t.LengthEncoding = encoding.LengthEnc
+	if t.Type == ElementTypeBinary {
+		t.TextEncoding = encoding.BinaryTextEnc
+	}
 	switch t.Type {
 	case ElementTypeNumeric:
 		t.Encoding = encoding.NumberEnc
@@ -135,4 +347,14 @@ func (t *ElementType) SetEncoding(encoding *EncodingDefinition) {
 	default:
 		t.Encoding = encoding.CharacterEnc
 	}
+	t.overrideEncoding()
+}
+
+func (t *ElementType) overrideEncoding() {
+	if t.encoding != "" {
+		t.Encoding = t.encoding
+	}
+	if t.lengthEncoding != "" {
+		t.LengthEncoding = t.lengthEncoding
+	}
 }
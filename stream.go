@@ -0,0 +1,241 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package iso8583
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/moov-io/iso8583/pkg/lib"
+	"github.com/moov-io/iso8583/pkg/utils"
+)
+
+var (
+	// ErrMessageTooLarge is given when the length of a message exceeds the
+	// maximum size of the stream
+	ErrMessageTooLarge = errors.New("message exceeds maximum size")
+	// ErrInvalidFraming is given when the length prefix of a message is
+	// malformed
+	ErrInvalidFraming = errors.New("invalid length prefix")
+	// ErrNoParser is given by Decoder.Decode without Parser and Specification
+	ErrNoParser = errors.New("neither parser nor specification")
+)
+
+// Framing is the length prefix of messages on a stream
+type Framing interface {
+	// Size of the prefix in bytes
+	Size() int
+	// MaxLength is the maximum message length of the prefix
+	MaxLength() int
+	// Encode returns prefix of message with length
+	Encode(length int) ([]byte, error)
+	// Decode returns message length of the prefix
+	Decode(prefix []byte) (int, error)
+}
+
+var (
+	// FramingBinary2 is 2 byte big-endian binary length
+	FramingBinary2 Framing = binaryFraming{}
+	// FramingASCII4 is 4 digit ASCII length
+	FramingASCII4 Framing = asciiFraming{}
+	// FramingBCD2 is 4 digit BCD length in 2 bytes
+	FramingBCD2 Framing = bcdFraming{}
+	// FramingRDW is 4 byte record descriptor word: 2 byte big-endian length
+	// including the RDW itself, followed by 2 zero bytes
+	FramingRDW Framing = rdwFraming{}
+)
+
+type binaryFraming struct{}
+
+func (binaryFraming) Size() int      { return 2 }
+func (binaryFraming) MaxLength() int { return 0xFFFF }
+
+func (binaryFraming) Encode(length int) ([]byte, error) {
+	prefix := make([]byte, 2)
+	binary.BigEndian.PutUint16(prefix, uint16(length))
+	return prefix, nil
+}
+
+func (binaryFraming) Decode(prefix []byte) (int, error) {
+	return int(binary.BigEndian.Uint16(prefix)), nil
+}
+
+type asciiFraming struct{}
+
+func (asciiFraming) Size() int      { return 4 }
+func (asciiFraming) MaxLength() int { return 9999 }
+
+func (asciiFraming) Encode(length int) ([]byte, error) {
+	return []byte(fmt.Sprintf("%04d", length)), nil
+}
+
+func (asciiFraming) Decode(prefix []byte) (int, error) {
+	length, err := strconv.Atoi(string(prefix))
+	if err != nil || length < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidFraming, prefix)
+	}
+	return length, nil
+}
+
+type bcdFraming struct{}
+
+func (bcdFraming) Size() int      { return 2 }
+func (bcdFraming) MaxLength() int { return 9999 }
+
+func (bcdFraming) Encode(length int) ([]byte, error) {
+	return bcd([]byte(fmt.Sprintf("%04d", length)))
+}
+
+func (bcdFraming) Decode(prefix []byte) (int, error) {
+	length, err := strconv.Atoi(string(bcd2Ascii(prefix)))
+	if err != nil {
+		return 0, fmt.Errorf("%w: % X", ErrInvalidFraming, prefix)
+	}
+	return length, nil
+}
+
+type rdwFraming struct{}
+
+func (rdwFraming) Size() int      { return 4 }
+func (rdwFraming) MaxLength() int { return 0xFFFF - 4 }
+
+func (rdwFraming) Encode(length int) ([]byte, error) {
+	prefix := make([]byte, 4)
+	binary.BigEndian.PutUint16(prefix, uint16(length+4))
+	return prefix, nil
+}
+
+func (rdwFraming) Decode(prefix []byte) (int, error) {
+	length := int(binary.BigEndian.Uint16(prefix))
+	if length < 4 || prefix[2] != 0 || prefix[3] != 0 {
+		return 0, fmt.Errorf("%w: % X", ErrInvalidFraming, prefix)
+	}
+	return length - 4, nil
+}
+
+// StreamOptions configure Encoder and Decoder
+type StreamOptions struct {
+	// Framing of messages, FramingBinary2 when nil
+	Framing Framing
+	// MaxSize is the maximum length of a message, the maximum length of
+	// Framing when 0
+	MaxSize int
+	// Parser decodes messages read by Decoder
+	Parser *Parser
+	// Specification decodes messages read by Decoder into
+	// lib.Iso8583Message, when Parser is nil
+	Specification *utils.Specification
+}
+
+func (o StreamOptions) framing() Framing {
+	if o.Framing == nil {
+		return FramingBinary2
+	}
+	return o.Framing
+}
+
+func (o StreamOptions) maxSize() int {
+	max := o.framing().MaxLength()
+	if o.MaxSize > 0 && o.MaxSize < max {
+		return o.MaxSize
+	}
+	return max
+}
+
+// Decoder reads successive length prefixed messages from a stream
+type Decoder struct {
+	r    io.Reader
+	opts StreamOptions
+}
+
+// NewDecoder creates new Decoder reading from r
+func NewDecoder(r io.Reader, opts StreamOptions) *Decoder {
+	return &Decoder{r, opts}
+}
+
+// ReadRaw reads bytes of the next message without the length prefix. It
+// returns io.EOF when the stream ends before the message and
+// io.ErrUnexpectedEOF when it ends within the message.
+func (d *Decoder) ReadRaw() ([]byte, error) {
+	framing := d.opts.framing()
+	prefix := make([]byte, framing.Size())
+	if _, err := io.ReadFull(d.r, prefix); err != nil {
+		return nil, err
+	}
+	length, err := framing.Decode(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if max := d.opts.maxSize(); length > max {
+		return nil, fmt.Errorf("%w: %d bytes, maximum %d", ErrMessageTooLarge, length, max)
+	}
+	raw := make([]byte, length)
+	if _, err := io.ReadFull(d.r, raw); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return raw, nil
+}
+
+// Decode reads the next message and parses it with Parser, or decodes it
+// with Specification into Data of lib.Iso8583Message
+func (d *Decoder) Decode() (*Message, error) {
+	if d.opts.Parser == nil && d.opts.Specification == nil {
+		return nil, ErrNoParser
+	}
+	raw, err := d.ReadRaw()
+	if err != nil {
+		return nil, err
+	}
+	if d.opts.Parser != nil {
+		return d.opts.Parser.Parse(raw)
+	}
+	generic, err := lib.NewISO8583Message(d.opts.Specification)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{Data: generic}
+	return msg, msg.Load(raw)
+}
+
+// Encoder writes successive length prefixed messages to a stream
+type Encoder struct {
+	w    io.Writer
+	opts StreamOptions
+}
+
+// NewEncoder creates new Encoder writing to w
+func NewEncoder(w io.Writer, opts StreamOptions) *Encoder {
+	return &Encoder{w, opts}
+}
+
+// WriteRaw writes message bytes with the length prefix
+func (e *Encoder) WriteRaw(raw []byte) error {
+	if max := e.opts.maxSize(); len(raw) > max {
+		return fmt.Errorf("%w: %d bytes, maximum %d", ErrMessageTooLarge, len(raw), max)
+	}
+	prefix, err := e.opts.framing().Encode(len(raw))
+	if err != nil {
+		return err
+	}
+	// write prefix and message at once, so they aren't split by other
+	// writers of the stream
+	_, err = e.w.Write(append(prefix, raw...))
+	return err
+}
+
+// Encode writes bytes of the message with the length prefix
+func (e *Encoder) Encode(m *Message) error {
+	raw, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+	return e.WriteRaw(raw)
+}
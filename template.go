@@ -0,0 +1,265 @@
+// Copyright 2020 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package iso8583
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type fieldInfo struct {
+	Index     int
+	Encode    int
+	LenEncode int
+	Length    int
+	// Type and Format of native Go values
+	Type   string
+	Format string
+	// OmitEmpty omits zero native Go values from the message
+	OmitEmpty bool
+	// position of the field in template struct
+	structIndex int
+}
+
+// template is the field plan of a template type. It is compiled once from
+// the struct tags and reused by Message and Parser.
+type template struct {
+	// fields ordered by field index
+	fields  []*fieldInfo
+	byIndex map[int]*fieldInfo
+	// struct positions of DataField pointers initialized by Parser
+	ptrFields []int
+	// problems found by Parser.Register, which fail Bytes or Load of
+	// every message
+	problems TemplateErrors
+}
+
+// templates caches compiled templates by reflect.Type
+var templates sync.Map
+
+func templateOf(tp reflect.Type) (*template, error) {
+	if tpl, ok := templates.Load(tp); ok {
+		return tpl.(*template), nil
+	}
+	tpl, err := compileTemplate(tp)
+	if err != nil {
+		return nil, err
+	}
+	templates.Store(tp, tpl)
+	return tpl, nil
+}
+
+// templateValue returns struct value of msg with its compiled template
+func templateValue(msg interface{}) (reflect.Value, *template, error) {
+	v := reflect.Indirect(reflect.ValueOf(msg))
+	if v.Kind() != reflect.Struct {
+		return v, nil, &TemplateError{Err: errors.New("data must be a struct")}
+	}
+	tpl, err := templateOf(v.Type())
+	return v, tpl, err
+}
+
+func compileTemplate(tp reflect.Type) (*template, error) {
+	if tp.Kind() != reflect.Struct {
+		return nil, &TemplateError{Err: errors.New("data must be a struct")}
+	}
+
+	tpl := &template{byIndex: make(map[int]*fieldInfo)}
+	// all problems, hard ones make the template unusable
+	var errs TemplateErrors
+	var hard bool
+	for i := 0; i < tp.NumField(); i++ {
+		sf := tp.Field(i)
+		if sf.Tag == "" || sf.Tag.Get(TagField) == "" {
+			continue
+		}
+
+		// values of unexported fields can't be read or set
+		if sf.PkgPath != "" {
+			hard, errs = true, append(errs, templateError(sf, "field must be exported"))
+			continue
+		}
+
+		index, err := strconv.Atoi(sf.Tag.Get(TagField))
+		if err != nil {
+			hard, errs = true, append(errs, templateError(sf, "value of field must be numeric"))
+			continue
+		}
+		if f, ok := tpl.byIndex[index]; ok {
+			hard, errs = true, append(errs, templateError(sf, fmt.Sprintf("field %d is already defined by %s", index, tp.Field(f.structIndex).Name)))
+			continue
+		}
+
+		encode := 0
+		lenEncode := 0
+		if raw := sf.Tag.Get(TagEncode); raw != "" {
+			enc := strings.Split(raw, ",")
+			if len(enc) == 2 {
+				lenEncode = parseEncodeStr(enc[0])
+				encode = parseEncodeStr(enc[1])
+			} else {
+				encode = parseEncodeStr(enc[0])
+			}
+		}
+
+		length := -1
+		if l := sf.Tag.Get(TagLength); l != "" {
+			length, err = strconv.Atoi(l)
+			if err != nil {
+				hard, errs = true, append(errs, templateError(sf, "value of length must be numeric"))
+				continue
+			}
+		}
+
+		typ, omitEmpty := parseTypeTag(sf.Tag.Get(TagType))
+		format := sf.Tag.Get(TagFormat)
+		if typ != "" {
+			if err := checkNativeField(sf, typ, format); err != nil {
+				hard, errs = true, append(errs, err)
+				continue
+			}
+			if length == -1 && format != "" {
+				length = len(format)
+			}
+		} else if sf.Type.Kind() != reflect.Interface && !sf.Type.Implements(dataFieldType) {
+			hard, errs = true, append(errs, templateError(sf, "field must be Iso8583Type"))
+			continue
+		}
+
+		if sf.Type.Kind() == reflect.Ptr && sf.Type.Implements(dataFieldType) {
+			tpl.ptrFields = append(tpl.ptrFields, i)
+		}
+		info := &fieldInfo{index, encode, lenEncode, length, typ, format, omitEmpty, i}
+		tpl.byIndex[index] = info
+		problems := info.check(sf)
+		tpl.problems = append(tpl.problems, problems...)
+		errs = append(errs, problems...)
+	}
+	if hard {
+		return nil, errs
+	}
+
+	for _, info := range tpl.byIndex {
+		tpl.fields = append(tpl.fields, info)
+	}
+	sort.Slice(tpl.fields, func(i, j int) bool {
+		return tpl.fields[i].Index < tpl.fields[j].Index
+	})
+	return tpl, nil
+}
+
+// fieldRule describes encoders and length supported by a DataField type
+type fieldRule struct {
+	encoders []int
+	// encoders of length head, nil for fixed length fields
+	lenEncoders []int
+	// maximum length of variable length fields
+	maxLength int
+	// maximum length with bin length encoder, 0 if it isn't supported
+	binMaxLength int
+}
+
+var (
+	charEncoders    = []int{ASCII, CP1252, LATIN1, UTF8, BINARY, EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141}
+	numericEncoders = []int{ASCII, BCD, rBCD, EBCDIC, EBCDIC273, EBCDIC1140, EBCDIC1141}
+	binLenEncoders  = append([]int{BIN}, numericEncoders...)
+)
+
+// fieldRules are the rules enforced by Bytes and Load of DataField types
+var fieldRules = map[reflect.Type]fieldRule{
+	reflect.TypeOf(Numeric{}):      {encoders: numericEncoders},
+	reflect.TypeOf(Alphanumeric{}): {encoders: charEncoders},
+	reflect.TypeOf(Binary{}):       {encoders: []int{ASCII}},
+	reflect.TypeOf(Llvar{}):        {charEncoders, numericEncoders, 99, 0},
+	reflect.TypeOf(Lllvar{}):       {charEncoders, numericEncoders, 999, 0},
+	reflect.TypeOf(L8var{}):        {charEncoders, numericEncoders, 99999999, 0},
+	reflect.TypeOf(Llnumeric{}):    {numericEncoders, numericEncoders, 99, 0},
+	reflect.TypeOf(Lllnumeric{}):   {numericEncoders, numericEncoders, 999, 0},
+	reflect.TypeOf(Llbin{}):        {[]int{ASCII}, binLenEncoders, 99, 255},
+	reflect.TypeOf(Lllbin{}):       {[]int{ASCII}, binLenEncoders, 999, 65535},
+}
+
+// check returns problems of the field which would fail Bytes or Load of
+// every message. Fields of interface type are checked at runtime only.
+func (f *fieldInfo) check(sf reflect.StructField) TemplateErrors {
+	var errs TemplateErrors
+	if f.Index < 2 || f.Index > 192 {
+		errs = append(errs, templateError(sf, "value of field must be between 2 and 192"))
+	} else if f.Index == 65 {
+		errs = append(errs, templateError(sf, "field 65 is reserved for tertiary bitmap"))
+	}
+
+	tp := indirectType(sf.Type)
+	if f.Type != "" {
+		tp = indirectType(reflect.TypeOf(fieldTypes[f.Type]()))
+	}
+	rule, ok := fieldRules[tp]
+	if !ok {
+		return errs
+	}
+	name := tp.Name()
+
+	encoders := strings.Split(sf.Tag.Get(TagEncode), ",")
+	encoder := encoders[len(encoders)-1]
+	if encoder != "" && !containsEncoder(rule.encoders, f.Encode) {
+		errs = append(errs, templateError(sf, fmt.Sprintf("encoding %s isn't supported by %s", encoder, name)))
+	}
+	if len(encoders) == 2 {
+		if rule.lenEncoders == nil {
+			errs = append(errs, templateError(sf, "length encoding isn't supported by "+name))
+		} else if !containsEncoder(rule.lenEncoders, f.LenEncode) {
+			errs = append(errs, templateError(sf, fmt.Sprintf("length encoding %s isn't supported by %s", encoders[0], name)))
+		}
+	}
+
+	maxLength := rule.maxLength
+	if f.LenEncode == BIN && rule.binMaxLength > 0 {
+		maxLength = rule.binMaxLength
+	}
+	switch {
+	case rule.lenEncoders == nil && f.Length == -1:
+		errs = append(errs, templateError(sf, "length is required by "+name))
+	case f.Length != -1 && f.Length < 1:
+		errs = append(errs, templateError(sf, "value of length must be positive"))
+	case rule.lenEncoders != nil && f.Length > maxLength:
+		errs = append(errs, templateError(sf, fmt.Sprintf("length %d exceeds maximum %d of %s", f.Length, maxLength, name)))
+	}
+	return errs
+}
+
+func containsEncoder(encoders []int, encoder int) bool {
+	for _, e := range encoders {
+		if e == encoder {
+			return true
+		}
+	}
+	return false
+}
+
+// dataField returns DataField of the field in template value v, or nil if
+// the field is a nil pointer
+func (f *fieldInfo) dataField(v reflect.Value) (DataField, error) {
+	fv := v.Field(f.structIndex)
+	if f.Type != "" {
+		return &nativeField{fv, fieldTypes[f.Type](), f.Format, f.OmitEmpty}, nil
+	}
+	if isPtrOrInterface(fv.Kind()) && fv.IsNil() {
+		return nil, nil
+	}
+	field, ok := fv.Interface().(DataField)
+	if !ok {
+		return nil, templateError(v.Type().Field(f.structIndex), "field must be Iso8583Type")
+	}
+	return field, nil
+}
+
+func isPtrOrInterface(k reflect.Kind) bool {
+	return k == reflect.Interface || k == reflect.Ptr
+}